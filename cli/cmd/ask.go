@@ -0,0 +1,373 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/arvid/remote-ai-ide/cli/internal/client"
+	"github.com/arvid/remote-ai-ide/cli/internal/policy"
+	"github.com/spf13/cobra"
+)
+
+var (
+	askProject string
+	askSession string
+	askOutput  string
+)
+
+var askCmd = &cobra.Command{
+	Use:   "ask [prompt]",
+	Short: "Send a single prompt and stream the answer to stdout",
+	Long: `Send a single prompt and stream the answer to stdout.
+
+The prompt is taken from the arguments. Without arguments it is read from
+stdin, and a "-" argument is replaced by stdin, so all of these work:
+
+  remote-ai-ide-cli ask --project . "explain the failing test"
+  git diff | remote-ai-ide-cli ask
+  go test ./... 2>&1 | remote-ai-ide-cli ask "why does this fail?" -
+
+The exit code is non-zero when the server reports the turn as failed.`,
+	Annotations: map[string]string{usesProjectFile: "true"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		switch askOutput {
+		case "text", "json", "ndjson":
+		default:
+			return fmt.Errorf("--output must be text, json or ndjson")
+		}
+
+		prompt, err := readPrompt(args)
+		if err != nil {
+			return err
+		}
+		cmd.SilenceUsage = true
+
+		srv, err := cfg.FindServer(serverName)
+		if err != nil {
+			return err
+		}
+		token, err := serverToken(srv)
+		if err != nil {
+			return err
+		}
+		rest := client.NewRESTClient(srv.URL, token, clientOptions()...)
+		warnUntrusted(srv)
+
+		id := askSession
+		var project string
+		var since int
+		if id == "" {
+			project, err = resolveProject(srv, askProject)
+			if err != nil {
+				return err
+			}
+			session, err := rest.CreateSession(project)
+			if err != nil {
+				return fmt.Errorf("create session: %w", err)
+			}
+			id = session.ID
+		} else {
+			detail, err := rest.GetSession(id, 0)
+			if err != nil {
+				return err
+			}
+			project = detail.ProjectPath
+			for _, m := range detail.Messages {
+				since = max(since, m.Seq)
+			}
+		}
+		fmt.Fprintf(os.Stderr, "Session: %s\n", id)
+
+		pol, err := loadPolicy(srv, project)
+		if err != nil {
+			return err
+		}
+
+		ctx := cmd.Context()
+		conn, err := client.Dial(ctx, srv.URL, token, connOptions(srv)...)
+		if err != nil {
+			return fmt.Errorf("websocket: %w", err)
+		}
+		defer closeConn(conn)
+		defer recordTranscript(conn, srv.Name)()
+
+		// Numbered now, so that if it has to be sent again it keeps its
+		// number
+		msg := conn.Number(client.NewUserMessage(id, prompt))
+		// One not sent for the connection dropping goes again on resync
+		if err := conn.Send(ctx, msg); err != nil && !errors.Is(err, client.ErrNotSent) {
+			return fmt.Errorf("send: %w", err)
+		}
+
+		run := &askRun{conn: conn, rest: rest, policy: pol, sessionID: id, msg: msg, output: askOutput, out: os.Stdout, startSeq: since, lastSeq: since}
+		return run.wait(ctx)
+	},
+}
+
+// askRun follows a single turn until the server reports its result.
+type askRun struct {
+	conn      *client.Conn
+	rest      *client.RESTClient
+	policy    *policy.Policy
+	sessionID string
+	msg       client.UserMessage
+	output    string
+	out       io.Writer
+
+	frames   []json.RawMessage // collected for --output json
+	streamed bool
+	printed  string // text of the answer printed so far
+	startSeq int    // last seq of the session before this run
+	lastSeq  int
+}
+
+func (r *askRun) wait(ctx context.Context) error {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt)
+	defer signal.Stop(sigs)
+
+	for {
+		select {
+		case ev, ok := <-r.conn.Events():
+			if !ok {
+				return r.finish(errors.New("connection lost"))
+			}
+			switch ev := ev.(type) {
+			case client.Reconnecting:
+				fmt.Fprintf(os.Stderr, "Reconnecting (%d/%d)...\n", ev.Attempt, ev.MaxAttempts)
+			case client.Reconnected:
+				if done, err := r.resync(ctx); done {
+					return r.finish(err)
+				}
+			case client.QueueChanged:
+				if ev.Depth > 0 {
+					fmt.Fprintf(os.Stderr, "Rate limited, %d frame(s) queued...\n", ev.Depth)
+				}
+			case client.Disconnected:
+				return r.finish(fmt.Errorf("connection lost: %w", ev.Err))
+			case client.SeqGap:
+				if ev.SessionID == r.sessionID {
+					fmt.Fprintf(os.Stderr, "Warning: missed frames %d-%d from the server; tool calls among them are lost\n", ev.From, ev.To)
+				}
+			case client.Rejected:
+				// One lost with the connection is sent again on resync
+				if !ev.Lost {
+					return r.finish(errors.New("the server's rate limit kept turning the prompt away; try again in a minute"))
+				}
+			case client.Latency, client.BadFrame:
+			default:
+				if done, err := r.handle(ctx, ev); done {
+					return r.finish(err)
+				}
+			}
+
+		case <-sigs:
+			ctx, cancel := context.WithTimeout(ctx, closeTimeout)
+			r.conn.Send(ctx, client.NewInterrupt(r.sessionID))
+			cancel()
+			return r.finish(errors.New("interrupted"))
+		}
+	}
+}
+
+// handle processes one server frame and reports whether the turn is over.
+func (r *askRun) handle(ctx context.Context, ev client.Event) (bool, error) {
+	if sid := client.FrameSessionID(ev); sid != "" && sid != r.sessionID {
+		return false, nil
+	}
+
+	if r.output != "text" {
+		// As received, so fields the client does not know are kept
+		var data bytes.Buffer
+		if err := json.Compact(&data, ev.(interface{ Raw() json.RawMessage }).Raw()); err != nil {
+			return true, err
+		}
+		if r.output == "ndjson" {
+			fmt.Fprintf(r.out, "%s\n", data.Bytes())
+		} else {
+			r.frames = append(r.frames, json.RawMessage(data.Bytes()))
+		}
+	}
+
+	switch m := ev.(type) {
+	case *client.AssistantChunk:
+		r.observeSeq(m.Seq)
+		if r.output == "text" {
+			fmt.Fprint(r.out, m.Content)
+			r.printed += m.Content
+			r.streamed = true
+		}
+
+	case *client.AssistantMessageMsg:
+		r.observeSeq(m.Seq)
+		r.printReply(m.Content)
+
+	case *client.ToolEvent:
+		r.observeSeq(m.Seq)
+		fmt.Fprintf(os.Stderr, "[tool] %s\n", m.ToolName)
+
+	case *client.PermissionRequest:
+		req := m
+		// Nobody can answer a prompt here, so "ask" means deny
+		d := r.policy.Decide(req.ToolName, req.ToolInput)
+		allowed := d.Action == policy.Allow
+		verdict, rule := "denied", d.Rule
+		if allowed {
+			verdict = "allowed"
+		}
+		if rule == "" {
+			rule = "no rule matched"
+		}
+		fmt.Fprintf(os.Stderr, "[permission] %s %s (%s)\n", verdict, req.ToolName, rule)
+		if err := r.conn.Send(ctx, client.NewPermissionResponse(r.sessionID, req.RequestID, allowed)); err != nil {
+			return true, err
+		}
+
+	case *client.ResultMessage:
+		result := m
+		if !result.Success {
+			if result.Error == "" {
+				return true, errors.New("turn failed")
+			}
+			return true, errors.New(result.Error)
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+// resync fetches what was missed during a reconnect. If the answer arrived
+// meanwhile the turn is complete. Only an assistant message after this
+// run's prompt counts; frames of the turn seen already show the prompt
+// was recorded before them. The server records a prompt as it receives
+// it, so one missing from history was lost with the connection and is
+// sent again.
+func (r *askRun) resync(ctx context.Context) (bool, error) {
+	if err := r.conn.Send(ctx, client.NewSwitchSession(r.sessionID)); err != nil {
+		return true, fmt.Errorf("resync: %w", err)
+	}
+	detail, err := r.rest.GetSession(r.sessionID, r.lastSeq)
+	if err != nil {
+		return true, fmt.Errorf("resync: %w", err)
+	}
+	asked := r.lastSeq > r.startSeq
+	for _, m := range detail.Messages {
+		if m.Role == "user" && m.Content == r.msg.Text {
+			asked = true
+		}
+		if m.Role != "assistant" || !asked {
+			continue
+		}
+		r.printReply(m.Content)
+		return true, nil
+	}
+	if !asked {
+		fmt.Fprintln(os.Stderr, "The prompt was lost with the connection; sending it again")
+		if err := r.conn.Send(ctx, r.msg); err != nil && !errors.Is(err, client.ErrNotSent) {
+			return true, fmt.Errorf("resend: %w", err)
+		}
+	}
+	return false, nil
+}
+
+// printReply prints the final reply in text mode, or as much of it as the
+// streamed chunks have not shown. If chunks were lost, the reply is
+// printed whole below what was shown.
+func (r *askRun) printReply(content string) {
+	if r.output != "text" {
+		return
+	}
+	switch {
+	case !r.streamed:
+		fmt.Fprint(r.out, content)
+	case strings.HasPrefix(content, r.printed):
+		fmt.Fprint(r.out, content[len(r.printed):])
+	case strings.HasSuffix(r.printed, content):
+		// The reply is the text after the last tool call, shown already
+	default:
+		fmt.Fprint(r.out, "\n\n"+content)
+	}
+	r.printed = content
+	r.streamed = true
+}
+
+func (r *askRun) observeSeq(seq int) {
+	if seq > r.lastSeq {
+		r.lastSeq = seq
+	}
+}
+
+func (r *askRun) finish(turnErr error) error {
+	switch r.output {
+	case "text":
+		if r.streamed {
+			fmt.Fprintln(r.out)
+		}
+	case "json":
+		if r.frames == nil {
+			r.frames = []json.RawMessage{}
+		}
+		data, err := json.MarshalIndent(r.frames, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(r.out, "%s\n", data)
+	}
+	return turnErr
+}
+
+// readPrompt joins the arguments, reading stdin in place of a "-" argument
+// or when no arguments are given. Stdin can be read only once, so at most
+// one "-" is allowed.
+func readPrompt(args []string) (string, error) {
+	if len(args) == 0 {
+		args = []string{"-"}
+	}
+
+	dashes := 0
+	for _, a := range args {
+		if a == "-" {
+			dashes++
+		}
+	}
+	if dashes > 1 {
+		return "", fmt.Errorf(`"-" given more than once; stdin can be read only once`)
+	}
+
+	parts := make([]string, 0, len(args))
+	for _, a := range args {
+		if a != "-" {
+			parts = append(parts, a)
+			continue
+		}
+		if isTerminal(os.Stdin) {
+			return "", fmt.Errorf("no prompt given; pass it as an argument or pipe it on stdin")
+		}
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return "", fmt.Errorf("reading stdin: %w", err)
+		}
+		parts = append(parts, "\n\n"+strings.TrimSpace(string(data))+"\n\n")
+	}
+
+	prompt := strings.TrimSpace(strings.Join(parts, " "))
+	if prompt == "" {
+		return "", fmt.Errorf("no prompt given; pass it as an argument or pipe it on stdin")
+	}
+	return prompt, nil
+}
+
+func init() {
+	askCmd.Flags().StringVar(&askProject, "project", "", "project path (defaults to cwd)")
+	askCmd.Flags().StringVar(&askSession, "session", "", "reuse an existing session instead of creating one")
+	askCmd.Flags().StringVarP(&askOutput, "output", "o", "text", "output format: text, json or ndjson")
+	askCmd.MarkFlagsMutuallyExclusive("session", "project")
+	rootCmd.AddCommand(askCmd)
+}
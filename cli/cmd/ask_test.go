@@ -0,0 +1,286 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/arvid/remote-ai-ide/cli/internal/client"
+	"github.com/gorilla/websocket"
+)
+
+// fakeBackend serves /ws and a session's history. Each WebSocket
+// connection is handed to serve, numbered from 1; returning drops it.
+type fakeBackend struct {
+	*httptest.Server
+	serve func(n int, conn *websocket.Conn)
+
+	mu      sync.Mutex
+	history []client.HistoryMessage
+	conns   int
+	prompts []client.UserMessage
+}
+
+func newFakeBackend(t *testing.T, serve func(b *fakeBackend, n int, conn *websocket.Conn)) *fakeBackend {
+	b := &fakeBackend{}
+	b.serve = func(n int, conn *websocket.Conn) { serve(b, n, conn) }
+	upgrader := websocket.Upgrader{}
+	b.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ws":
+			conn, err := upgrader.Upgrade(w, r, nil)
+			if err != nil {
+				return
+			}
+			b.mu.Lock()
+			b.conns++
+			n := b.conns
+			b.mu.Unlock()
+			b.serve(n, conn)
+			conn.Close()
+		case "/api/sessions/s":
+			since, _ := strconv.Atoi(r.URL.Query().Get("since"))
+			detail := client.SessionDetail{Session: client.Session{ID: "s"}, Messages: []client.HistoryMessage{}}
+			b.mu.Lock()
+			for _, m := range b.history {
+				if m.Seq > since {
+					detail.Messages = append(detail.Messages, m)
+				}
+			}
+			b.mu.Unlock()
+			json.NewEncoder(w).Encode(detail)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(b.Close)
+	return b
+}
+
+// prompt waits for the next user message on conn and records it.
+func (b *fakeBackend) prompt(conn *websocket.Conn) (client.UserMessage, bool) {
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return client.UserMessage{}, false
+		}
+		var m client.UserMessage
+		if json.Unmarshal(data, &m) == nil && m.Type == "user_message" {
+			b.mu.Lock()
+			b.prompts = append(b.prompts, m)
+			b.mu.Unlock()
+			return m, true
+		}
+	}
+}
+
+func (b *fakeBackend) record(role, content string, seq int) {
+	b.mu.Lock()
+	b.history = append(b.history, client.HistoryMessage{Role: role, Content: content, Seq: seq})
+	b.mu.Unlock()
+}
+
+func send(conn *websocket.Conn, frames ...string) {
+	for _, f := range frames {
+		conn.WriteMessage(websocket.TextMessage, []byte(f))
+	}
+}
+
+// answer sends a whole turn after the user message numbered seq.
+func answer(b *fakeBackend, conn *websocket.Conn, seq int, text string) {
+	b.record("assistant", text, seq+2)
+	send(conn,
+		`{"type":"assistant_chunk","sessionId":"s","content":`+strconv.Quote(text)+`,"seq":`+strconv.Itoa(seq+1)+`}`,
+		`{"type":"assistant_message","sessionId":"s","content":`+strconv.Quote(text)+`,"seq":`+strconv.Itoa(seq+2)+`}`,
+		`{"type":"result","sessionId":"s","success":true,"seq":`+strconv.Itoa(seq+2)+`}`)
+}
+
+// runAsk sends the prompt "hi" as ask does and follows the turn.
+func runAsk(t *testing.T, b *fakeBackend, output string) (string, error) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	conn, err := client.Dial(ctx, b.URL, "t",
+		client.WithReconnect(client.ReconnectPolicy{MaxAttempts: 3, MaxDelay: 20 * time.Millisecond}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeConn(conn)
+
+	msg := conn.Number(client.NewUserMessage("s", "hi"))
+	if err := conn.Send(ctx, msg); err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	r := &askRun{conn: conn, rest: client.NewRESTClient(b.URL, "t"), sessionID: "s", msg: msg, output: output, out: &out}
+	done := make(chan error, 1)
+	go func() { done <- r.wait(ctx) }()
+	select {
+	case err = <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("ask did not finish")
+	}
+	return out.String(), err
+}
+
+func TestAskResendsLostPrompt(t *testing.T) {
+	b := newFakeBackend(t, func(b *fakeBackend, n int, conn *websocket.Conn) {
+		if _, ok := b.prompt(conn); !ok || n == 1 {
+			// Lost with the connection before the server recorded it
+			return
+		}
+		b.record("user", "hi", 1)
+		answer(b, conn, 1, "hello")
+		b.prompt(conn)
+	})
+
+	out, err := runAsk(t, b, "text")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != "hello\n" {
+		t.Errorf("output %q, want %q", out, "hello\n")
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.prompts) != 2 || b.prompts[1].Seq != b.prompts[0].Seq {
+		t.Errorf("server got %+v, want the prompt twice with one number", b.prompts)
+	}
+}
+
+func TestAskCompletesTruncatedAnswer(t *testing.T) {
+	tests := []struct {
+		name  string
+		serve func(b *fakeBackend, n int, conn *websocket.Conn)
+		want  string
+	}{
+		{
+			name: "dropped mid-stream",
+			serve: func(b *fakeBackend, n int, conn *websocket.Conn) {
+				if n > 1 {
+					b.prompt(conn)
+					return
+				}
+				b.prompt(conn)
+				b.record("user", "hi", 1)
+				send(conn, `{"type":"assistant_chunk","sessionId":"s","content":"Hello ","seq":2}`)
+				// The answer finishes while the client is away
+				b.record("assistant", "Hello world", 4)
+			},
+			want: "Hello world\n",
+		},
+		{
+			name: "chunk lost",
+			serve: func(b *fakeBackend, n int, conn *websocket.Conn) {
+				b.prompt(conn)
+				b.record("user", "hi", 1)
+				b.record("assistant", "A B C", 5)
+				send(conn,
+					`{"type":"assistant_chunk","sessionId":"s","content":"A ","seq":2}`,
+					`{"type":"assistant_chunk","sessionId":"s","content":"C","seq":4}`,
+					`{"type":"assistant_message","sessionId":"s","content":"A B C","seq":5}`,
+					`{"type":"result","sessionId":"s","success":true,"seq":5}`)
+				b.prompt(conn)
+			},
+			want: "A C\n\nA B C\n",
+		},
+		{
+			name: "reply is the text after a tool call",
+			serve: func(b *fakeBackend, n int, conn *websocket.Conn) {
+				b.prompt(conn)
+				b.record("user", "hi", 1)
+				b.record("assistant", "done", 5)
+				send(conn,
+					`{"type":"assistant_chunk","sessionId":"s","content":"Looking. ","seq":2}`,
+					`{"type":"tool_event","sessionId":"s","toolName":"Read","toolInput":{},"seq":3}`,
+					`{"type":"assistant_chunk","sessionId":"s","content":"done","seq":4}`,
+					`{"type":"assistant_message","sessionId":"s","content":"done","seq":5}`,
+					`{"type":"result","sessionId":"s","success":true,"seq":5}`)
+				b.prompt(conn)
+			},
+			want: "Looking. done\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newFakeBackend(t, tt.serve)
+			out, err := runAsk(t, b, "text")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if out != tt.want {
+				t.Errorf("output %q, want %q", out, tt.want)
+			}
+		})
+	}
+}
+
+func TestAskOutputsFramesAsReceived(t *testing.T) {
+	serve := func(b *fakeBackend, n int, conn *websocket.Conn) {
+		b.prompt(conn)
+		send(conn,
+			`{"type":"assistant_chunk","sessionId":"s","content":"hi","seq":2,"model":"x"}`,
+			`{"type":"assistant_message","sessionId":"s","content":"hi","seq":3}`,
+			`{"type":"result","sessionId":"s","success":true,"seq":3,"costUsd":0.01}`)
+		b.prompt(conn)
+	}
+	for _, output := range []string{"json", "ndjson"} {
+		t.Run(output, func(t *testing.T) {
+			b := newFakeBackend(t, serve)
+			out, err := runAsk(t, b, output)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(out, `"model":"x"`) && !strings.Contains(out, `"model": "x"`) {
+				t.Errorf("output lost the chunk's unknown field:\n%s", out)
+			}
+			if !strings.Contains(out, "costUsd") {
+				t.Errorf("output lost the result's unknown field:\n%s", out)
+			}
+			if output == "ndjson" && strings.Count(out, "\n") != 3 {
+				t.Errorf("ndjson output is not one frame per line:\n%s", out)
+			}
+		})
+	}
+}
+
+func TestAskFailedTurn(t *testing.T) {
+	tests := []struct {
+		name  string
+		frame string
+		want  string
+	}{
+		{"with an error", `{"type":"result","sessionId":"s","success":false,"error":"Session not found","seq":0}`, "Session not found"},
+		{"without one", `{"type":"result","sessionId":"s","success":false,"seq":0}`, "turn failed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newFakeBackend(t, func(b *fakeBackend, n int, conn *websocket.Conn) {
+				b.prompt(conn)
+				send(conn, tt.frame)
+				b.prompt(conn)
+			})
+			_, err := runAsk(t, b, "text")
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("ask returned %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadPromptOneStdin(t *testing.T) {
+	if _, err := readPrompt([]string{"-", "and", "-"}); err == nil || !strings.Contains(err.Error(), "more than once") {
+		t.Errorf("readPrompt with two \"-\" = %v, want it refused", err)
+	}
+	got, err := readPrompt([]string{"fix", "the", "tests"})
+	if err != nil || got != "fix the tests" {
+		t.Errorf("readPrompt of arguments = %q, %v; want them joined", got, err)
+	}
+}
@@ -1,27 +1,45 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"path/filepath"
+	"sort"
+	"time"
 
 	"github.com/arvid/remote-ai-ide/cli/internal/client"
+	"github.com/arvid/remote-ai-ide/cli/internal/config"
+	"github.com/arvid/remote-ai-ide/cli/internal/outbox"
+	"github.com/arvid/remote-ai-ide/cli/internal/policy"
+	"github.com/arvid/remote-ai-ide/cli/internal/transcript"
 	"github.com/arvid/remote-ai-ide/cli/internal/tui"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/spf13/cobra"
 )
 
-var projectPath string
+var (
+	projectPath   string
+	sessionID     string
+	resumeSession bool
+)
 
 var connectCmd = &cobra.Command{
-	Use:   "connect",
-	Short: "Connect to a Remote AI IDE server",
+	Use:         "connect",
+	Short:       "Connect to a Remote AI IDE server",
+	Annotations: map[string]string{usesProjectFile: "true"},
 	RunE: func(cmd *cobra.Command, args []string) error {
 		srv, err := cfg.FindServer(serverName)
 		if err != nil {
 			return err
 		}
 
-		rest := client.NewRESTClient(srv.URL, srv.Token)
+		token, err := serverToken(srv)
+		if err != nil {
+			return err
+		}
+		rest := client.NewRESTClient(srv.URL, token, clientOptions()...)
+		warnUntrusted(srv)
 
 		// Health check
 		fmt.Fprintf(os.Stderr, "Connecting to %s (%s)...\n", srv.Name, srv.URL)
@@ -29,36 +47,84 @@ var connectCmd = &cobra.Command{
 		if err != nil {
 			return fmt.Errorf("server unreachable: %w", err)
 		}
-		fmt.Fprintf(os.Stderr, "Server OK: %s, %d active sessions\n", health.Status, health.ActiveSessions)
+		fmt.Fprintf(os.Stderr, "Server OK: %s\n", healthSummary(health))
 
-		// Resolve project path
-		project := projectPath
-		if project == "" {
-			cwd, err := os.Getwd()
+		id := sessionID
+		if resumeSession && id == "" {
+			id, err = pickSession(rest)
 			if err != nil {
 				return err
 			}
-			project = cwd
 		}
 
-		// Create session
-		fmt.Fprintf(os.Stderr, "Creating session for %s...\n", project)
-		session, err := rest.CreateSession(project)
+		attached := id != ""
+		var history []client.HistoryMessage
+		var project string
+		if attached {
+			// Attach to an existing session and backfill its history
+			detail, err := rest.GetSession(id, 0)
+			if err != nil {
+				return fmt.Errorf("get session: %w", err)
+			}
+			history = detail.Messages
+			project = detail.ProjectPath
+			fmt.Fprintf(os.Stderr, "Attaching to session %s (%s, %d messages)\n", detail.ID, detail.ProjectPath, len(history))
+		} else {
+			project, err = chooseProject(srv, rest, projectPath)
+			if err != nil {
+				return err
+			}
+
+			// Create session
+			fmt.Fprintf(os.Stderr, "Creating session for %s...\n", project)
+			session, err := rest.CreateSession(project)
+			if err != nil {
+				return fmt.Errorf("create session: %w", err)
+			}
+			id = session.ID
+			fmt.Fprintf(os.Stderr, "Session: %s\n", id)
+		}
+
+		pol, err := loadPolicy(srv, project)
 		if err != nil {
-			return fmt.Errorf("create session: %w", err)
+			return err
 		}
-		fmt.Fprintf(os.Stderr, "Session: %s\n", session.ID)
 
 		// Connect WebSocket
-		ws, err := client.NewWSClient(srv.URL, srv.Token)
+		conn, err := client.Dial(cmd.Context(), srv.URL, token, connOptions(srv)...)
 		if err != nil {
 			return fmt.Errorf("websocket: %w", err)
 		}
-		defer ws.Close()
+		defer closeConn(conn)
+		defer recordTranscript(conn, srv.Name)()
+
+		if attached {
+			if err := conn.Send(cmd.Context(), client.NewSwitchSession(id)); err != nil {
+				return fmt.Errorf("switch session: %w", err)
+			}
+		}
+
+		ob, err := outbox.Open(outbox.Path(transcript.DefaultDir(), srv.Name))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
 
 		// Launch TUI
-		model := tui.NewModel(ws, session.ID, srv.Name)
-		p := tea.NewProgram(model, tea.WithAltScreen())
+		model := tui.NewModel(conn, tui.Options{
+			REST:       rest,
+			SessionID:  id,
+			Project:    project,
+			ServerName: srv.Name,
+			Paths:      srv,
+			History:    history,
+			Policy:     pol,
+			LoadPolicy: func(project string) (*policy.Policy, error) {
+				return loadPolicy(srv, project)
+			},
+			Outbox: ob,
+			Logger: logger,
+		})
+		p := tea.NewProgram(model, tea.WithAltScreen(), tea.WithMouseCellMotion())
 		if _, err := p.Run(); err != nil {
 			return err
 		}
@@ -66,7 +132,158 @@ var connectCmd = &cobra.Command{
 	},
 }
 
+// closeTimeout bounds how long closing a connection waits for the server
+// to acknowledge.
+const closeTimeout = 2 * time.Second
+
+func closeConn(conn *client.Conn) {
+	ctx, cancel := context.WithTimeout(context.Background(), closeTimeout)
+	defer cancel()
+	conn.Close(ctx)
+}
+
+// resolveProject turns the --project flag into an absolute path, defaulting
+// to the project file's pinned project and then cwd, and translates it with
+// the server's path mappings.
+func resolveProject(srv *config.Server, p string) (string, error) {
+	if p == "" && projFile != nil && projFile.AppliesTo(srv) && projFile.Project != "" {
+		return projFile.Project, nil
+	}
+	var err error
+	if p == "" {
+		p, err = os.Getwd()
+	} else {
+		p, err = filepath.Abs(p)
+	}
+	if err != nil {
+		return "", err
+	}
+	return srv.ToRemote(p), nil
+}
+
+// loadPolicy builds the permission policy for a project on srv, from the
+// most specific rules to the least.
+func loadPolicy(srv *config.Server, project string) (*policy.Policy, error) {
+	var scopes []policy.Scope
+	if projFile != nil && projFile.Covers(srv, project) {
+		rules := projFile.Permissions
+		if !cfg.Trusts(projFile) {
+			rules = withoutAllow(rules)
+		}
+		scopes = append(scopes, policy.Scope{Name: "project file " + projFile.Path, Rules: rules})
+	}
+	if p := srv.FindProject(project); p != nil {
+		scopes = append(scopes, policy.Scope{Name: "project " + p.Path, Rules: p.Permissions})
+	}
+	scopes = append(scopes,
+		policy.Scope{Name: "server " + srv.Name, Rules: srv.Permissions},
+		policy.Scope{Name: "global", Rules: cfg.Permissions},
+	)
+	return policy.New(scopes...)
+}
+
+// withoutAllow drops the allow rules of an untrusted project file, so a
+// cloned repository can tighten the policy but not loosen it.
+func withoutAllow(rules []config.PermissionRule) []config.PermissionRule {
+	var kept []config.PermissionRule
+	for _, r := range rules {
+		if r.Action == string(policy.Allow) {
+			continue
+		}
+		kept = append(kept, r)
+	}
+	return kept
+}
+
+// warnUntrusted says when a project file's allow rules are being ignored.
+func warnUntrusted(srv *config.Server) {
+	if projFile == nil || !projFile.AppliesTo(srv) || cfg.Trusts(projFile) {
+		return
+	}
+	for _, r := range projFile.Permissions {
+		if r.Action == string(policy.Allow) {
+			fmt.Fprintf(os.Stderr, "Ignoring allow rules in %s; add %s to trusted_projects in %s to use them\n",
+				projFile.Path, projFile.Dir(), cfgFile)
+			return
+		}
+	}
+}
+
+// chooseProject resolves --project. Without it, an interactive terminal
+// gets a picker of the server's projects, since the local working directory
+// often does not exist on the server; it is still offered as the last
+// choice. Without a terminal the working directory is used as before.
+func chooseProject(srv *config.Server, rest *client.RESTClient, flag string) (string, error) {
+	if flag != "" || !isTerminal(os.Stdin) || (projFile != nil && projFile.AppliesTo(srv) && projFile.Project != "") {
+		return resolveProject(srv, flag)
+	}
+	cwd, err := resolveProject(srv, "")
+	if err != nil {
+		return "", err
+	}
+	projects, err := rest.ListProjects()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Could not list projects (%v), using %s\n", err, cwd)
+		return cwd, nil
+	}
+	if len(projects) == 0 {
+		return cwd, nil
+	}
+	sortProjects(projects)
+
+	var items []tui.PickerItem
+	for _, p := range projects {
+		item := tui.PickerItem{ID: p.Path, Label: p.Name, Desc: p.Path}
+		if p.Path == cwd {
+			// Working in a checkout that exists on the server too
+			items = append([]tui.PickerItem{item}, items...)
+			continue
+		}
+		items = append(items, item)
+	}
+	desc := cwd + " (may not exist on the server)"
+	if srv.ToLocal(cwd) != cwd {
+		desc = cwd + " (mapped from " + srv.ToLocal(cwd) + ")"
+	}
+	items = append(items, tui.PickerItem{ID: cwd, Label: "Current directory", Desc: desc})
+	return tui.Pick("Select a project", items)
+}
+
+func isTerminal(f *os.File) bool {
+	stat, err := f.Stat()
+	return err == nil && stat.Mode()&os.ModeCharDevice != 0
+}
+
+// pickSession lets the user choose one of the server's sessions, most recent first.
+func pickSession(rest *client.RESTClient) (string, error) {
+	sessions, err := rest.ListSessions()
+	if err != nil {
+		return "", fmt.Errorf("list sessions: %w", err)
+	}
+	if len(sessions) == 0 {
+		return "", fmt.Errorf("no sessions on server; run connect without --resume to start one")
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastActivity > sessions[j].LastActivity
+	})
+
+	items := make([]tui.PickerItem, len(sessions))
+	for i, s := range sessions {
+		items[i] = tui.PickerItem{
+			ID:    s.ID,
+			Label: fmt.Sprintf("%s  %s", shortID(s.ID), s.ProjectPath),
+			Desc:  fmt.Sprintf("%s · %d messages · %s", s.Status, s.MessageCount, relativeTime(s.LastActivity)),
+		}
+	}
+	return tui.Pick("Select a session", items)
+}
+
 func init() {
-	connectCmd.Flags().StringVar(&projectPath, "project", "", "project path (defaults to cwd)")
+	connectCmd.Flags().StringVar(&projectPath, "project", "", "project path (default: pick one of the server's projects)")
+	connectCmd.Flags().StringVar(&sessionID, "session", "", "attach to an existing session by ID")
+	connectCmd.Flags().BoolVar(&resumeSession, "resume", false, "pick an existing session to attach to")
+	connectCmd.MarkFlagsMutuallyExclusive("session", "resume")
+	connectCmd.MarkFlagsMutuallyExclusive("session", "project")
+	connectCmd.MarkFlagsMutuallyExclusive("resume", "project")
 	rootCmd.AddCommand(connectCmd)
 }
@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/arvid/remote-ai-ide/cli/internal/config"
+	"github.com/arvid/remote-ai-ide/cli/internal/policy"
+)
+
+func TestLoadPolicyUntrustedProjectFile(t *testing.T) {
+	dir := t.TempDir()
+	file := &config.ProjectFile{
+		Path: filepath.Join(dir, config.ProjectFileName),
+		Permissions: []config.PermissionRule{
+			{Action: "allow", Tool: "Bash"},
+			{Action: "deny", Tool: "Bash", Input: map[string]string{"command": "*kubectl delete*"}},
+			{Action: "ask", Tool: "Read"},
+		},
+	}
+	srv := &config.Server{Name: "local"}
+
+	tests := []struct {
+		name    string
+		trusted []string
+		tool    string
+		input   string
+		want    policy.Action
+	}{
+		{"untrusted allow is dropped", nil, "Bash", `{"command":"ls"}`, policy.Ask},
+		{"untrusted deny applies", nil, "Bash", `{"command":"kubectl delete ns x"}`, policy.Deny},
+		{"untrusted ask applies over the config", nil, "Read", `{}`, policy.Ask},
+		{"trusted allow applies", []string{dir}, "Bash", `{"command":"ls"}`, policy.Allow},
+		{"trusted deny still wins", []string{dir}, "Bash", `{"command":"kubectl delete ns x"}`, policy.Deny},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			oldCfg, oldFile := cfg, projFile
+			t.Cleanup(func() { cfg, projFile = oldCfg, oldFile })
+			cfg = &config.Config{
+				TrustedProjects: tt.trusted,
+				Permissions:     []config.PermissionRule{{Action: "allow", Tool: "Read"}},
+			}
+			projFile = file
+
+			p, err := loadPolicy(srv, filepath.ToSlash(dir))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if d := p.Decide(tt.tool, []byte(tt.input)); d.Action != tt.want {
+				t.Errorf("Decide(%s, %s) = %s (%s), want %s", tt.tool, tt.input, d.Action, d.Rule, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithoutAllow(t *testing.T) {
+	rules := []config.PermissionRule{
+		{Action: "allow", Tool: "Read"},
+		{Action: "deny", Tool: "Bash"},
+		{Action: "allow", Tool: "Grep"},
+		{Action: "ask", Tool: "Write"},
+	}
+	got := withoutAllow(rules)
+	if len(got) != 2 || got[0].Action != "deny" || got[1].Action != "ask" {
+		t.Errorf("withoutAllow kept %+v, want the deny and ask rules", got)
+	}
+}
@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/arvid/remote-ai-ide/cli/internal/doctor"
+	"github.com/spf13/cobra"
+)
+
+var doctorJSON bool
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor [name]",
+	Short: "Diagnose the connection to a server",
+	Long: `Check each step of reaching a server: DNS, TCP, the TLS certificate,
+/health, the token on /api, the WebSocket upgrade on /ws and whether a
+session can be created. The capacity check creates a session and deletes
+it again. Exits non-zero if any check fails.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name := serverName
+		if len(args) == 1 {
+			name = args[0]
+		}
+		srv, err := cfg.FindServer(name)
+		if err != nil {
+			return err
+		}
+		cmd.SilenceUsage = true
+
+		token, tokenErr := serverToken(srv)
+		results := doctor.Run(context.Background(), doctor.Target{
+			Name:     srv.Name,
+			URL:      srv.URL,
+			Token:    token,
+			TokenErr: tokenErr,
+		})
+		failed := doctor.Failed(results)
+
+		if doctorJSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			err := enc.Encode(struct {
+				Server string          `json:"server"`
+				URL    string          `json:"url"`
+				OK     bool            `json:"ok"`
+				Checks []doctor.Result `json:"checks"`
+			}{srv.Name, srv.URL, failed == 0, results})
+			if err != nil {
+				return err
+			}
+		} else {
+			printDoctor(srv.Name, srv.URL, results)
+		}
+		if failed > 0 {
+			return fmt.Errorf("%d of %d checks failed", failed, len(results))
+		}
+		return nil
+	},
+}
+
+func printDoctor(name, url string, results []doctor.Result) {
+	fmt.Printf("Server %s (%s)\n\n", name, url)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CHECK\tSTATUS\tTIME\tDETAIL")
+	fmt.Fprintln(w, "-----\t------\t----\t------")
+	for _, r := range results {
+		elapsed := "-"
+		if r.Status != doctor.Skip {
+			elapsed = fmt.Sprintf("%dms", r.DurationMs)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", r.Check, strings.ToUpper(string(r.Status)), elapsed, r.Detail)
+	}
+	w.Flush()
+
+	var hints []string
+	for _, r := range results {
+		if r.Hint != "" {
+			hints = append(hints, fmt.Sprintf("  %s: %s", r.Check, r.Hint))
+		}
+	}
+	if len(hints) > 0 {
+		fmt.Printf("\nHints:\n%s\n", strings.Join(hints, "\n"))
+	}
+}
+
+func init() {
+	doctorCmd.Flags().BoolVar(&doctorJSON, "json", false, "print the results as JSON")
+	rootCmd.AddCommand(doctorCmd)
+}
@@ -0,0 +1,31 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+)
+
+// relativeTime formats a millisecond Unix timestamp as "5m ago".
+func relativeTime(ms int64) string {
+	if ms <= 0 {
+		return "-"
+	}
+	d := time.Since(time.UnixMilli(ms))
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		return fmt.Sprintf("%dm ago", int(d.Minutes()))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh ago", int(d.Hours()))
+	default:
+		return fmt.Sprintf("%dd ago", int(d.Hours()/24))
+	}
+}
+
+func shortID(id string) string {
+	if len(id) > 8 {
+		return id[:8]
+	}
+	return id
+}
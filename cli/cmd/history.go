@@ -0,0 +1,215 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/arvid/remote-ai-ide/cli/internal/client"
+	"github.com/arvid/remote-ai-ide/cli/internal/transcript"
+	"github.com/spf13/cobra"
+)
+
+var (
+	historyAll    bool
+	historyRaw    bool
+	historyNoCase bool
+)
+
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "Browse local session transcripts offline",
+	Long: `Browse local session transcripts offline.
+
+Every frame sent to or received from a session is appended to
+~/.local/share/remote-ai-ide/<server>/<session>.jsonl ($XDG_DATA_HOME is
+honoured), so conversations stay readable after the server has dropped them.`,
+}
+
+var historyListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List transcripts",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		infos, err := transcript.List(transcript.DefaultDir(), historyServer())
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		if historyAll {
+			fmt.Fprint(w, "SERVER\t")
+		}
+		fmt.Fprintln(w, "ID\tMESSAGES\tLAST ACTIVITY\tFIRST PROMPT")
+		if historyAll {
+			fmt.Fprint(w, "------\t")
+		}
+		fmt.Fprintln(w, "--\t--------\t-------------\t------------")
+		for _, info := range infos {
+			entries, err := transcript.Read(info.Path)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", info.Path, err)
+				continue
+			}
+			count, prompt := 0, ""
+			for _, m := range transcript.Messages(entries) {
+				if m.Role != "user" && m.Role != "assistant" {
+					continue
+				}
+				count++
+				if prompt == "" && m.Role == "user" {
+					prompt = firstLine(m.Content, 50)
+				}
+			}
+			if historyAll {
+				fmt.Fprintf(w, "%s\t", info.Server)
+			}
+			fmt.Fprintf(w, "%s\t%d\t%s\t%s\n", info.SessionID, count, relativeTime(info.Modified.UnixMilli()), prompt)
+		}
+		w.Flush()
+		return nil
+	},
+}
+
+var historyShowCmd = &cobra.Command{
+	Use:   "show [id]",
+	Short: "Print a transcript",
+	Long:  "Print a transcript. The session ID may be abbreviated to any unique prefix.",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		info, err := transcript.Find(transcript.DefaultDir(), historyServer(), args[0])
+		if err != nil {
+			return err
+		}
+		if historyRaw {
+			data, err := os.ReadFile(info.Path)
+			if err != nil {
+				return err
+			}
+			_, err = os.Stdout.Write(data)
+			return err
+		}
+		entries, err := transcript.Read(info.Path)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Session %s on %s\n", info.SessionID, info.Server)
+		for _, m := range transcript.Messages(entries) {
+			ts := m.Time.Local().Format("2006-01-02 15:04:05")
+			switch m.Role {
+			case "user", "assistant", "error":
+				fmt.Printf("\n%s %s\n%s\n", ts, m.Role, m.Content)
+			case "tool":
+				fmt.Printf("\n%s tool %s %s\n", ts, m.ToolName, compactInput(m.ToolInput))
+			case "permission":
+				verdict := m.Content
+				if verdict == "" {
+					verdict = "unanswered"
+				}
+				fmt.Printf("\n%s permission %s %s: %s\n", ts, m.ToolName, compactInput(m.ToolInput), verdict)
+			}
+		}
+		return nil
+	},
+}
+
+var historyGrepCmd = &cobra.Command{
+	Use:   "grep [pattern]",
+	Short: "Search transcripts with a regular expression",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		expr := args[0]
+		if historyNoCase {
+			expr = "(?i)" + expr
+		}
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return fmt.Errorf("invalid pattern: %w", err)
+		}
+		cmd.SilenceUsage = true
+
+		infos, err := transcript.List(transcript.DefaultDir(), historyServer())
+		if err != nil {
+			return err
+		}
+		found := 0
+		for _, info := range infos {
+			entries, err := transcript.Read(info.Path)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", info.Path, err)
+				continue
+			}
+			for _, m := range transcript.Messages(entries) {
+				text := m.Content
+				if m.Role == "tool" || m.Role == "permission" {
+					text = m.ToolName + " " + compactInput(m.ToolInput)
+				}
+				for _, line := range strings.Split(text, "\n") {
+					if !re.MatchString(line) {
+						continue
+					}
+					found++
+					prefix := shortID(info.SessionID)
+					if historyAll {
+						prefix = info.Server + "/" + prefix
+					}
+					fmt.Printf("%s %s %s: %s\n", prefix, m.Time.Local().Format("2006-01-02 15:04"), m.Role, line)
+				}
+			}
+		}
+		if found == 0 {
+			return fmt.Errorf("no matches")
+		}
+		return nil
+	},
+}
+
+// recordTranscript logs all traffic on ws to the local transcripts. The
+// returned function stops recording and reports any write error.
+func recordTranscript(ws *client.Conn, server string) func() {
+	store := transcript.Open(transcript.DefaultDir(), server)
+	ws.SetRecorder(store.Record)
+	return func() {
+		ws.SetRecorder(nil)
+		if err := store.Close(); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+	}
+}
+
+// historyServer returns the server whose transcripts to read; empty means all.
+func historyServer() string {
+	if historyAll {
+		return ""
+	}
+	return serverName
+}
+
+func compactInput(raw json.RawMessage) string {
+	var buf bytes.Buffer
+	if json.Compact(&buf, raw) != nil {
+		return string(raw)
+	}
+	return firstLine(buf.String(), 100)
+}
+
+// firstLine returns the first line of s, cut to at most n runes.
+func firstLine(s string, n int) string {
+	s, _, _ = strings.Cut(strings.TrimSpace(s), "\n")
+	if r := []rune(s); len(r) > n {
+		return string(r[:n-3]) + "..."
+	}
+	return s
+}
+
+func init() {
+	historyCmd.PersistentFlags().BoolVar(&historyAll, "all-servers", false, "include transcripts of every server")
+	historyShowCmd.Flags().BoolVar(&historyRaw, "raw", false, "print the JSONL file as stored")
+	historyGrepCmd.Flags().BoolVarP(&historyNoCase, "ignore-case", "i", false, "match case-insensitively")
+	historyCmd.AddCommand(historyListCmd, historyShowCmd, historyGrepCmd)
+	rootCmd.AddCommand(historyCmd)
+}
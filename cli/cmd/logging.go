@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+
+	"github.com/arvid/remote-ai-ide/cli/internal/client"
+)
+
+var (
+	logFile   string
+	logLevel  string
+	logFormat string
+	logFrames bool
+
+	logger  = slog.New(slog.DiscardHandler)
+	logDest io.Closer
+)
+
+// setupLogging opens --log-file. Without it nothing is logged, since stderr
+// belongs to the TUI; "-" logs to stderr for commands that have none.
+func setupLogging() error {
+	if logFile == "" {
+		return nil
+	}
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(logLevel)); err != nil {
+		return fmt.Errorf("--log-level must be debug, info, warn or error")
+	}
+	if logFrames && level > slog.LevelDebug {
+		// Frames are logged at debug level
+		level = slog.LevelDebug
+	}
+
+	var w io.Writer = os.Stderr
+	if logFile != "-" {
+		f, err := os.OpenFile(logFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+		if err != nil {
+			return fmt.Errorf("opening log file: %w", err)
+		}
+		w, logDest = f, f
+	}
+
+	hopts := &slog.HandlerOptions{Level: level}
+	switch logFormat {
+	case "text":
+		logger = slog.New(slog.NewTextHandler(w, hopts))
+	case "json":
+		logger = slog.New(slog.NewJSONHandler(w, hopts))
+	default:
+		return fmt.Errorf("--log-format must be text or json")
+	}
+	slog.SetDefault(logger)
+	return nil
+}
+
+func closeLogging() {
+	if logDest != nil {
+		logDest.Close()
+	}
+}
+
+// clientOptions passes the logging flags on to REST and WebSocket clients.
+func clientOptions() []client.Option {
+	return []client.Option{client.WithLogger(logger), client.WithFrameLog(logFrames)}
+}
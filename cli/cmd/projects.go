@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/arvid/remote-ai-ide/cli/internal/client"
+	"github.com/spf13/cobra"
+)
+
+var projectsCmd = &cobra.Command{
+	Use:   "projects",
+	Short: "List and create projects on the server",
+}
+
+var projectsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the git repositories found on the server",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		srv, err := cfg.FindServer(serverName)
+		if err != nil {
+			return err
+		}
+		rest, err := restClient(srv)
+		if err != nil {
+			return err
+		}
+		projects, err := rest.ListProjects()
+		if err != nil {
+			return err
+		}
+		if len(projects) == 0 {
+			fmt.Println("No projects found. Create one with: remote-ai-ide-cli projects create <name>")
+			return nil
+		}
+		sortProjects(projects)
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tPATH")
+		fmt.Fprintln(w, "----\t----")
+		for _, p := range projects {
+			fmt.Fprintf(w, "%s\t%s\n", p.Name, p.Path)
+		}
+		w.Flush()
+		return nil
+	},
+}
+
+var projectsCreateCmd = &cobra.Command{
+	Use:   "create [name]",
+	Short: "Create a git repository in the server user's home directory",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		srv, err := cfg.FindServer(serverName)
+		if err != nil {
+			return err
+		}
+		rest, err := restClient(srv)
+		if err != nil {
+			return err
+		}
+		p, err := rest.CreateProject(args[0])
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Created project %s at %s\n", p.Name, p.Path)
+		return nil
+	},
+}
+
+func sortProjects(projects []client.Project) {
+	sort.Slice(projects, func(i, j int) bool {
+		return projects[i].Name < projects[j].Name
+	})
+}
+
+func init() {
+	projectsCmd.AddCommand(projectsListCmd)
+	projectsCmd.AddCommand(projectsCreateCmd)
+	rootCmd.AddCommand(projectsCmd)
+}
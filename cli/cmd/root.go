@@ -12,29 +12,88 @@ var (
 	cfgFile    string
 	serverName string
 	cfg        *config.Config
+	projFile   *config.ProjectFile // nil outside a project with a project file
 )
 
+// usesProjectFile annotates the commands that act on a project's settings
+// and so fail when its project file cannot be read; others warn.
+const usesProjectFile = "uses-project-file"
+
+// serverEnv names the server to use when --server is not given.
+const serverEnv = "REMOTE_AI_IDE_SERVER"
+
 var rootCmd = &cobra.Command{
 	Use:   "remote-ai-ide-cli",
 	Short: "Terminal client for Remote AI IDE",
 	Long:  "Connect to Remote AI IDE servers and interact with Claude from your terminal.",
 	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := setupLogging(); err != nil {
+			return err
+		}
 		var err error
 		cfg, err = config.Load(cfgFile)
 		if err != nil {
 			return fmt.Errorf("config: %w", err)
 		}
+		if cwd, err := os.Getwd(); err == nil {
+			projFile, err = config.FindProjectFile(cwd, cfgFile, config.DefaultPath())
+			if err != nil {
+				// Commands such as doctor and servers edit must still run,
+				// to help fix what is wrong
+				if cmd.Annotations[usesProjectFile] != "" {
+					return err
+				}
+				fmt.Fprintf(os.Stderr, "Warning: %v; ignoring the project file\n", err)
+			}
+		}
+		if !cmd.Flags().Changed("server") {
+			serverName = defaultServer()
+		}
 		return nil
 	},
 }
 
+// defaultServer picks the server when --server is not given: the
+// environment, then the project file, then default_server, then "local".
+func defaultServer() string {
+	if name := os.Getenv(serverEnv); name != "" {
+		return name
+	}
+	if projFile != nil && projFile.Server != "" {
+		return projFile.Server
+	}
+	if cfg.DefaultServer != "" {
+		return cfg.DefaultServer
+	}
+	return "local"
+}
+
+// serverSource says which setting defaultServer took its answer from.
+func serverSource() string {
+	switch {
+	case os.Getenv(serverEnv) != "":
+		return serverEnv
+	case projFile != nil && projFile.Server != "":
+		return projFile.Path
+	case cfg.DefaultServer != "":
+		return "default_server"
+	}
+	return "the built-in default"
+}
+
 func Execute() {
-	if err := rootCmd.Execute(); err != nil {
+	err := rootCmd.Execute()
+	closeLogging()
+	if err != nil {
 		os.Exit(1)
 	}
 }
 
 func init() {
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", config.DefaultPath(), "config file path")
-	rootCmd.PersistentFlags().StringVar(&serverName, "server", "local", "server name from config")
+	rootCmd.PersistentFlags().StringVar(&logFile, "log-file", "", `write diagnostics to this file ("-" for stderr)`)
+	rootCmd.PersistentFlags().StringVar(&logLevel, "log-level", "info", "log level: debug, info, warn or error")
+	rootCmd.PersistentFlags().StringVar(&logFormat, "log-format", "text", "log format: text or json")
+	rootCmd.PersistentFlags().BoolVar(&logFrames, "log-frames", false, "log every WebSocket frame, with the token redacted")
+	rootCmd.PersistentFlags().StringVar(&serverName, "server", "", "server name from config (default: $"+serverEnv+", the project file, default_server, or local)")
 }
@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/arvid/remote-ai-ide/cli/internal/config"
+	"github.com/spf13/cobra"
+)
+
+func TestMalformedProjectFile(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, config.ProjectFileName), []byte("server: [oops\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(dir)
+	t.Setenv(serverEnv, "")
+	oldCfgFile, oldCfg, oldFile, oldServer := cfgFile, cfg, projFile, serverName
+	t.Cleanup(func() { cfgFile, cfg, projFile, serverName = oldCfgFile, oldCfg, oldFile, oldServer })
+	cfgFile = filepath.Join(t.TempDir(), "config.yaml")
+
+	tests := []struct {
+		cmd  *cobra.Command
+		fail bool
+	}{
+		{listCmd, false},
+		{editCmd, false},
+		{doctorCmd, false},
+		{connectCmd, true},
+		{askCmd, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.cmd.CommandPath(), func(t *testing.T) {
+			err := rootCmd.PersistentPreRunE(tt.cmd, nil)
+			if tt.fail && err == nil {
+				t.Error("ran with a malformed project file")
+			}
+			if !tt.fail && err != nil {
+				t.Errorf("failed for a malformed project file: %v", err)
+			}
+			if !tt.fail && projFile != nil {
+				t.Error("the malformed project file was used")
+			}
+		})
+	}
+}
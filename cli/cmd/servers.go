@@ -1,13 +1,18 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
+	"io"
+	neturl "net/url"
 	"os"
 	"strings"
 	"text/tabwriter"
 
 	"github.com/arvid/remote-ai-ide/cli/internal/client"
 	"github.com/arvid/remote-ai-ide/cli/internal/config"
+	"github.com/arvid/remote-ai-ide/cli/internal/secret"
+	"github.com/arvid/remote-ai-ide/cli/internal/tui"
 	"github.com/spf13/cobra"
 )
 
@@ -28,15 +33,24 @@ var listCmd = &cobra.Command{
 		fmt.Fprintln(w, "NAME\tURL\tTOKEN")
 		fmt.Fprintln(w, "----\t---\t-----")
 		for _, s := range cfg.Servers {
+			name := s.Name
+			if name == cfg.DefaultServer {
+				name += " (default)"
+			}
 			masked := maskToken(s.Token)
-			fmt.Fprintf(w, "%s\t%s\t%s\n", s.Name, s.URL, masked)
+			fmt.Fprintf(w, "%s\t%s\t%s\n", name, s.URL, masked)
 		}
 		w.Flush()
 		return nil
 	},
 }
 
+// maskToken hides a literal token; references are shown as written since
+// they say where the token lives, not what it is.
 func maskToken(t string) string {
+	if secret.IsReference(t) {
+		return t
+	}
 	if len(t) <= 4 {
 		return strings.Repeat("*", len(t))
 	}
@@ -44,32 +58,239 @@ func maskToken(t string) string {
 }
 
 var (
-	addName  string
-	addURL   string
-	addToken string
+	srvName       string
+	srvURL        string
+	srvToken      string
+	srvTokenStdin bool
+	srvPlaintext  bool
+	srvVerify     bool
+	srvDefault    bool
 )
 
 var addCmd = &cobra.Command{
 	Use:   "add",
 	Short: "Add a server profile",
+	Long: `Add a server profile. Without --name, --url and --token it asks for them
+interactively, checking the server and token as you go. Given by flags
+they are saved as they are; --verify checks them first.
+
+The token is stored in the system keyring, or in an encrypted file beside
+the config when no keyring is available. Pass it with --token-stdin to keep
+it out of shell history, or give a reference instead of the token:
+env:VAR reads an environment variable and cmd:COMMAND runs a command such
+as "pass show remote-ai-ide".`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		if addName == "" || addURL == "" || addToken == "" {
-			return fmt.Errorf("--name, --url, and --token are all required")
+		cmd.SilenceUsage = true
+		if err := readTokenStdin(); err != nil {
+			return err
 		}
-		for _, s := range cfg.Servers {
-			if s.Name == addName {
-				return fmt.Errorf("server %q already exists. Remove it first or choose a different name", addName)
+		if (srvName == "" || srvURL == "" || srvToken == "") && !srvTokenStdin && isTerminal(os.Stdin) {
+			return addInteractive()
+		}
+		if srvName == "" || srvURL == "" || srvToken == "" {
+			return fmt.Errorf("--name, --url, and --token or --token-stdin are all required")
+		}
+		if err := checkNewName(srvName); err != nil {
+			return err
+		}
+		url := strings.TrimRight(srvURL, "/")
+		if srvVerify {
+			if err := verifyServer(url, srvToken); err != nil {
+				return err
+			}
+		}
+		return addServer(srvName, url, srvToken, srvDefault)
+	},
+}
+
+func addInteractive() error {
+	res, err := tui.ServerForm(tui.ServerFormOptions{
+		Title:        "Add server",
+		Name:         srvName,
+		URL:          srvURL,
+		EditName:     true,
+		TokenHint:    "token, env:VAR or cmd:COMMAND",
+		ValidateName: checkNewName,
+		CheckURL:     checkURL,
+		CheckToken:   formCheckToken,
+		AskDefault:   !srvDefault,
+	})
+	if err != nil {
+		return err
+	}
+	if err := checkAfterForm(res.URL, res.Token); err != nil {
+		return err
+	}
+	return addServer(res.Name, res.URL, res.Token, res.MakeDefault || srvDefault)
+}
+
+func addServer(name, url, token string, makeDefault bool) error {
+	srv := config.Server{Name: name, URL: url}
+	undo, err := setToken(&srv, token)
+	if err != nil {
+		return err
+	}
+	cfg.Servers = append(cfg.Servers, srv)
+	if makeDefault {
+		cfg.DefaultServer = name
+	}
+	if err := config.Save(cfgFile, cfg); err != nil {
+		undo()
+		return fmt.Errorf("saving config: %w", err)
+	}
+	fmt.Printf("Added server %q (%s)\n", name, url)
+	if makeDefault {
+		fmt.Printf("%q is now the default server\n", name)
+	}
+	return nil
+}
+
+var editCmd = &cobra.Command{
+	Use:   "edit [name]",
+	Short: "Change a server's URL or token",
+	Long: `Change a server's URL or token. Without --url or --token it opens the
+interactive form; leave the token empty there to keep the current one.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		srv, err := cfg.FindServer(args[0])
+		if err != nil {
+			return err
+		}
+		if err := readTokenStdin(); err != nil {
+			return err
+		}
+		url, token, makeDefault := srvURL, srvToken, srvDefault
+		if url == "" && token == "" && !srvTokenStdin && isTerminal(os.Stdin) {
+			// Resolved up front: it may prompt or run a command, which
+			// cannot share the terminal with the form
+			current, currentErr := serverToken(srv)
+			res, err := tui.ServerForm(tui.ServerFormOptions{
+				Title:         "Edit server " + srv.Name,
+				Name:          srv.Name,
+				URL:           srv.URL,
+				TokenHint:     "leave empty to keep the current token",
+				TokenOptional: true,
+				CheckURL:      checkURL,
+				CheckToken: func(url, token string) error {
+					if token == "" {
+						if currentErr != nil {
+							return currentErr
+						}
+						return checkResolvedToken(url, current)
+					}
+					return formCheckToken(url, token)
+				},
+				AskDefault: !srvDefault && cfg.DefaultServer != srv.Name,
+			})
+			if err != nil {
+				return err
+			}
+			if err := checkAfterForm(res.URL, res.Token); err != nil {
+				return err
+			}
+			url, token, makeDefault = res.URL, res.Token, res.MakeDefault || srvDefault
+		} else if srvVerify {
+			check := token
+			if check == "" {
+				if check, err = serverToken(srv); err != nil {
+					return err
+				}
+			}
+			checkAt := srv.URL
+			if url != "" {
+				checkAt = url
+			}
+			if err := verifyServer(strings.TrimRight(checkAt, "/"), check); err != nil {
+				return err
+			}
+		}
+
+		if url != "" {
+			srv.URL = strings.TrimRight(url, "/")
+		}
+		oldToken, undo := srv.Token, func() {}
+		if token != "" {
+			if undo, err = setToken(srv, token); err != nil {
+				return err
+			}
+		}
+		if makeDefault {
+			cfg.DefaultServer = srv.Name
+		}
+		if err := config.Save(cfgFile, cfg); err != nil {
+			undo()
+			return fmt.Errorf("saving config: %w", err)
+		}
+		fmt.Printf("Updated server %q (%s)\n", srv.Name, srv.URL)
+		if oldToken != srv.Token {
+			forgetSecret(oldToken)
+		}
+		return nil
+	},
+}
+
+var renameCmd = &cobra.Command{
+	Use:   "rename [old] [new]",
+	Short: "Rename a server profile",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		oldName, newName := args[0], args[1]
+		srv, err := cfg.FindServer(oldName)
+		if err != nil {
+			return err
+		}
+		if err := checkNewName(newName); err != nil {
+			return err
+		}
+
+		// A token stored under the old name moves with the server
+		oldToken, undo := srv.Token, func() {}
+		srv.Name = newName
+		if oldToken == secret.SecretPrefix+oldName {
+			token, err := serverToken(&config.Server{Name: oldName, Token: oldToken})
+			if err != nil {
+				return err
 			}
+			if undo, err = setToken(srv, token); err != nil {
+				return err
+			}
+		}
+		if cfg.DefaultServer == oldName {
+			cfg.DefaultServer = newName
+		}
+		if err := config.Save(cfgFile, cfg); err != nil {
+			undo()
+			return fmt.Errorf("saving config: %w", err)
+		}
+		fmt.Printf("Renamed server %q to %q\n", oldName, newName)
+		if oldToken != srv.Token {
+			forgetSecret(oldToken)
 		}
-		cfg.Servers = append(cfg.Servers, config.Server{
-			Name:  addName,
-			URL:   strings.TrimRight(addURL, "/"),
-			Token: addToken,
-		})
+		return nil
+	},
+}
+
+var useCmd = &cobra.Command{
+	Use:   "use [name]",
+	Short: "Make a server the default",
+	Long: `Make a server the default for commands run without --server. The
+REMOTE_AI_IDE_SERVER environment variable and a project file's server
+still take precedence.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		srv, err := cfg.FindServer(args[0])
+		if err != nil {
+			return err
+		}
+		cfg.DefaultServer = srv.Name
 		if err := config.Save(cfgFile, cfg); err != nil {
 			return fmt.Errorf("saving config: %w", err)
 		}
-		fmt.Printf("Added server %q (%s)\n", addName, addURL)
+		fmt.Printf("%q is now the default server\n", srv.Name)
+		if active := defaultServer(); active != srv.Name {
+			fmt.Printf("Commands here still use %q, set by %s\n", active, serverSource())
+		}
 		return nil
 	},
 }
@@ -80,27 +301,173 @@ var removeCmd = &cobra.Command{
 	Args:  cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		name := args[0]
-		found := false
+		var removed *config.Server
 		filtered := make([]config.Server, 0, len(cfg.Servers))
 		for _, s := range cfg.Servers {
 			if s.Name == name {
-				found = true
+				removed = &s
 				continue
 			}
 			filtered = append(filtered, s)
 		}
-		if !found {
+		if removed == nil {
 			return fmt.Errorf("server %q not found", name)
 		}
 		cfg.Servers = filtered
+		if cfg.DefaultServer == name {
+			cfg.DefaultServer = ""
+		}
 		if err := config.Save(cfgFile, cfg); err != nil {
 			return fmt.Errorf("saving config: %w", err)
 		}
 		fmt.Printf("Removed server %q\n", name)
+		forgetSecret(removed.Token)
 		return nil
 	},
 }
 
+// readTokenStdin fills srvToken from stdin for --token-stdin.
+func readTokenStdin() error {
+	if !srvTokenStdin {
+		return nil
+	}
+	if srvToken != "" {
+		return fmt.Errorf("--token and --token-stdin are mutually exclusive")
+	}
+	data, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return fmt.Errorf("reading token: %w", err)
+	}
+	srvToken = strings.TrimSpace(string(data))
+	if srvToken == "" {
+		return fmt.Errorf("no token on stdin")
+	}
+	return nil
+}
+
+func checkNewName(name string) error {
+	if name == "" {
+		return fmt.Errorf("name is required")
+	}
+	for _, s := range cfg.Servers {
+		if s.Name == name {
+			return fmt.Errorf("server %q already exists. Remove it first or choose a different name", name)
+		}
+	}
+	return nil
+}
+
+// verifyServer checks that url answers and accepts token.
+func verifyServer(url, token string) error {
+	if err := checkURL(url); err != nil {
+		return err
+	}
+	return checkToken(url, token)
+}
+
+func checkURL(raw string) error {
+	u, err := neturl.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("URL must look like http://host:port or https://host")
+	}
+	if _, err := client.NewRESTClient(raw, "").Health(); err != nil {
+		return fmt.Errorf("server unreachable: %w", err)
+	}
+	return nil
+}
+
+// checkToken resolves token and checks it against the server.
+func checkToken(url, token string) error {
+	resolved, err := secret.Resolve(token, secretStore)
+	if err != nil {
+		return err
+	}
+	return checkResolvedToken(url, resolved)
+}
+
+// checkResolvedToken calls an authenticated endpoint, since /health
+// answers without a token.
+func checkResolvedToken(url, token string) error {
+	_, err := client.NewRESTClient(url, token).ListSessions()
+	return err
+}
+
+// formCheckToken checks a token typed into the server form. The form has
+// the terminal in raw mode, so cmd: and secret: references, which may run
+// a command reading the terminal or prompt for a passphrase, are left for
+// checkAfterForm.
+func formCheckToken(url, token string) error {
+	if deferredToken(token) {
+		return tui.ErrCheckLater
+	}
+	return checkToken(url, token)
+}
+
+// checkAfterForm checks a token the form left unchecked, now that the
+// terminal is free.
+func checkAfterForm(url, token string) error {
+	if !deferredToken(token) {
+		return nil
+	}
+	if err := checkToken(url, token); err != nil {
+		return fmt.Errorf("%w (pass --url and --token to save without checking)", err)
+	}
+	return nil
+}
+
+func deferredToken(token string) bool {
+	return strings.HasPrefix(token, secret.CmdPrefix) || strings.HasPrefix(token, secret.SecretPrefix)
+}
+
+// setToken saves token for srv, in the secret store unless it is a
+// reference or --plaintext was given. The returned undo puts the secret
+// store back as it was, for when the config cannot be saved; the secret
+// the token replaces is the caller's to forget once it is.
+func setToken(srv *config.Server, token string) (undo func(), err error) {
+	undo = func() {}
+	if !srvPlaintext && !secret.IsReference(token) {
+		store := secretStore()
+		key := srv.Name
+		// Editing a token stored under the server's name overwrites it
+		prev, prevErr := "", error(secret.ErrNotFound)
+		if srv.Token == secret.SecretPrefix+key {
+			prev, prevErr = serverToken(srv)
+		}
+		if err := store.Set(key, token); err != nil {
+			return nil, fmt.Errorf("storing token: %w (use an env: or cmd: reference, or --plaintext)", err)
+		}
+		fmt.Printf("Stored token in %s\n", store.Name())
+		undo = func() {
+			var err error
+			if prevErr == nil {
+				err = store.Set(key, prev)
+			} else {
+				err = store.Delete(key)
+			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: could not put back the stored token: %v\n", err)
+			}
+		}
+		token = secret.SecretPrefix + key
+	}
+	srv.Token = token
+	delete(tokenCache, srv.Name)
+	return undo, nil
+}
+
+// forgetSecret deletes the stored secret token refers to, unless another
+// server still uses it.
+func forgetSecret(token string) {
+	key, ok := strings.CutPrefix(token, secret.SecretPrefix)
+	if !ok || sharedSecret(key) {
+		return
+	}
+	err := secretStore().Delete(key)
+	if err != nil && !errors.Is(err, secret.ErrNotFound) {
+		fmt.Fprintf(os.Stderr, "Warning: could not delete stored token: %v\n", err)
+	}
+}
+
 var testCmd = &cobra.Command{
 	Use:   "test [name]",
 	Short: "Test server connectivity",
@@ -119,26 +486,95 @@ var testCmd = &cobra.Command{
 			return nil
 		}
 		for _, s := range servers {
-			rest := client.NewRESTClient(s.URL, s.Token)
+			rest, err := restClient(&s)
+			if err != nil {
+				fmt.Printf("  %s (%s): FAILED - %v\n", s.Name, s.URL, err)
+				continue
+			}
 			health, err := rest.Health()
 			if err != nil {
 				fmt.Printf("  %s (%s): FAILED - %v\n", s.Name, s.URL, err)
 				continue
 			}
-			fmt.Printf("  %s (%s): OK - %d active sessions\n", s.Name, s.URL, health.ActiveSessions)
+			fmt.Printf("  %s (%s): OK - %s\n", s.Name, s.URL, healthSummary(health))
 		}
 		return nil
 	},
 }
 
+var migrateSecretsCmd = &cobra.Command{
+	Use:   "migrate-secrets",
+	Short: "Move plaintext tokens from the config file to the secret store",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		store := secretStore()
+		moved := 0
+		var migrateErr error
+		for i := range cfg.Servers {
+			s := &cfg.Servers[i]
+			if s.Token == "" || secret.IsReference(s.Token) {
+				continue
+			}
+			if err := store.Set(s.Name, s.Token); err != nil {
+				migrateErr = fmt.Errorf("storing token for %q: %w", s.Name, err)
+				break
+			}
+			s.Token = secret.SecretPrefix + s.Name
+			fmt.Printf("Moved token for %q to %s\n", s.Name, store.Name())
+			moved++
+		}
+		if moved == 0 {
+			if migrateErr != nil {
+				return migrateErr
+			}
+			fmt.Println("No plaintext tokens to migrate.")
+			return nil
+		}
+		// Save what was moved even if a later server failed, so the
+		// config matches the store
+		if err := config.Save(cfgFile, cfg); err != nil {
+			return fmt.Errorf("saving config: %w", err)
+		}
+		return migrateErr
+	},
+}
+
+// healthSummary describes a health response, pointing out when it came
+// from something other than the backend.
+func healthSummary(h *client.HealthResponse) string {
+	if h.PlainText {
+		return fmt.Sprintf("plain-text health response %q, probably from a proxy; run doctor to check the backend", h.Status)
+	}
+	return fmt.Sprintf("%d active sessions", h.ActiveSessions)
+}
+
+// sharedSecret reports whether any remaining server still uses key.
+func sharedSecret(key string) bool {
+	for _, s := range cfg.Servers {
+		if s.Token == secret.SecretPrefix+key {
+			return true
+		}
+	}
+	return false
+}
+
 func init() {
-	addCmd.Flags().StringVar(&addName, "name", "", "server name")
-	addCmd.Flags().StringVar(&addURL, "url", "", "server URL (e.g. http://localhost:3002)")
-	addCmd.Flags().StringVar(&addToken, "token", "", "auth token")
+	for _, c := range []*cobra.Command{addCmd, editCmd} {
+		c.Flags().StringVar(&srvURL, "url", "", "server URL (e.g. http://localhost:3002)")
+		c.Flags().StringVar(&srvToken, "token", "", "auth token, or an env:VAR or cmd:COMMAND reference")
+		c.Flags().BoolVar(&srvTokenStdin, "token-stdin", false, "read the auth token from stdin")
+		c.Flags().BoolVar(&srvPlaintext, "plaintext", false, "keep the token in the config file instead of the secret store")
+		c.Flags().BoolVar(&srvVerify, "verify", false, "check the server and token given by flags before saving")
+		c.Flags().BoolVar(&srvDefault, "default", false, "make this the default server")
+	}
+	addCmd.Flags().StringVar(&srvName, "name", "", "server name")
 
 	serversCmd.AddCommand(listCmd)
 	serversCmd.AddCommand(addCmd)
+	serversCmd.AddCommand(editCmd)
+	serversCmd.AddCommand(renameCmd)
+	serversCmd.AddCommand(useCmd)
 	serversCmd.AddCommand(removeCmd)
 	serversCmd.AddCommand(testCmd)
+	serversCmd.AddCommand(migrateSecretsCmd)
 	rootCmd.AddCommand(serversCmd)
 }
@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"maps"
+	"path/filepath"
+	"testing"
+
+	"github.com/arvid/remote-ai-ide/cli/internal/config"
+	"github.com/arvid/remote-ai-ide/cli/internal/secret"
+)
+
+// mapStore is a secret.Store backed by a map.
+type mapStore map[string]string
+
+func (mapStore) Name() string { return "the test store" }
+
+func (s mapStore) Get(key string) (string, error) {
+	v, ok := s[key]
+	if !ok {
+		return "", secret.ErrNotFound
+	}
+	return v, nil
+}
+
+func (s mapStore) Set(key, value string) error {
+	s[key] = value
+	return nil
+}
+
+func (s mapStore) Delete(key string) error {
+	if _, ok := s[key]; !ok {
+		return secret.ErrNotFound
+	}
+	delete(s, key)
+	return nil
+}
+
+func TestTokenKeptOnFailedSave(t *testing.T) {
+	oldCfgFile, oldCfg, oldSecrets := cfgFile, cfg, secrets
+	t.Cleanup(func() { cfgFile, cfg, secrets = oldCfgFile, oldCfg, oldSecrets })
+	// A config file in a missing directory cannot be saved
+	cfgFile = filepath.Join(t.TempDir(), "missing", "config.yaml")
+
+	tests := []struct {
+		name string
+		run  func() error
+	}{
+		{"add", func() error {
+			return addServer("new", "http://localhost:3002", "new-token", false)
+		}},
+		{"edit", func() error {
+			srvURL, srvToken = "", "new-token"
+			t.Cleanup(func() { srvToken = "" })
+			return editCmd.RunE(editCmd, []string{"work"})
+		}},
+		{"rename", func() error {
+			return renameCmd.RunE(renameCmd, []string{"work", "renamed"})
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := mapStore{"work": "old-token"}
+			want := maps.Clone(store)
+			secrets = store
+			clear(tokenCache)
+			cfg = &config.Config{Servers: []config.Server{{Name: "work", URL: "http://localhost:3002", Token: secret.SecretPrefix + "work"}}}
+			if err := tt.run(); err == nil {
+				t.Fatal("saved a config to a missing directory")
+			}
+			if !maps.Equal(store, want) {
+				t.Errorf("secret store = %v after the save failed, want %v", store, want)
+			}
+		})
+	}
+}
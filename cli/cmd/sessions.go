@@ -0,0 +1,266 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/arvid/remote-ai-ide/cli/internal/config"
+	"github.com/arvid/remote-ai-ide/cli/internal/export"
+	"github.com/arvid/remote-ai-ide/cli/internal/transcript"
+	"github.com/spf13/cobra"
+)
+
+var (
+	allServers bool
+	pruneIdle  time.Duration
+	pruneDry   bool
+
+	exportFormat string
+	exportSince  int
+	exportOutput string
+)
+
+var sessionsCmd = &cobra.Command{
+	Use:   "sessions",
+	Short: "List, inspect and delete server sessions",
+}
+
+var sessionsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List sessions",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		servers, err := targetServers()
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		if allServers {
+			fmt.Fprint(w, "SERVER\t")
+		}
+		fmt.Fprintln(w, "ID\tSTATUS\tPROJECT\tMESSAGES\tLAST ACTIVITY")
+		if allServers {
+			fmt.Fprint(w, "------\t")
+		}
+		fmt.Fprintln(w, "--\t------\t-------\t--------\t-------------")
+		for _, s := range servers {
+			rest, err := restClient(&s)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", s.Name, err)
+				continue
+			}
+			sessions, err := rest.ListSessions()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", s.Name, err)
+				continue
+			}
+			sort.Slice(sessions, func(i, j int) bool {
+				return sessions[i].LastActivity > sessions[j].LastActivity
+			})
+			for _, sess := range sessions {
+				if allServers {
+					fmt.Fprintf(w, "%s\t", s.Name)
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\n",
+					sess.ID, sess.Status, sess.ProjectPath, sess.MessageCount, relativeTime(sess.LastActivity))
+			}
+		}
+		w.Flush()
+		return nil
+	},
+}
+
+var sessionsShowCmd = &cobra.Command{
+	Use:   "show [id]",
+	Short: "Show a session and its message history",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		srv, err := cfg.FindServer(serverName)
+		if err != nil {
+			return err
+		}
+		rest, err := restClient(srv)
+		if err != nil {
+			return err
+		}
+		detail, err := rest.GetSession(args[0], 0)
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintf(w, "ID:\t%s\n", detail.ID)
+		fmt.Fprintf(w, "Project:\t%s\n", detail.ProjectPath)
+		fmt.Fprintf(w, "Status:\t%s\n", detail.Status)
+		fmt.Fprintf(w, "Messages:\t%d\n", detail.MessageCount)
+		fmt.Fprintf(w, "Last activity:\t%s\n", relativeTime(detail.LastActivity))
+		w.Flush()
+
+		for _, m := range detail.Messages {
+			ts := time.UnixMilli(m.Timestamp).Format("2006-01-02 15:04:05")
+			fmt.Printf("\n[%d] %s %s\n%s\n", m.Seq, ts, m.Role, m.Content)
+		}
+		return nil
+	},
+}
+
+var sessionsDeleteCmd = &cobra.Command{
+	Use:   "delete [id...]",
+	Short: "Delete one or more sessions",
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		srv, err := cfg.FindServer(serverName)
+		if err != nil {
+			return err
+		}
+		rest, err := restClient(srv)
+		if err != nil {
+			return err
+		}
+		failed := 0
+		for _, id := range args {
+			if err := rest.DeleteSession(id); err != nil {
+				fmt.Fprintf(os.Stderr, "%v\n", err)
+				failed++
+				continue
+			}
+			fmt.Printf("Deleted session %s\n", id)
+		}
+		if failed > 0 {
+			return fmt.Errorf("%d of %d deletions failed", failed, len(args))
+		}
+		return nil
+	},
+}
+
+var sessionsPruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Delete sessions that have been idle for too long",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if pruneIdle <= 0 {
+			return fmt.Errorf("--idle must be positive")
+		}
+		servers, err := targetServers()
+		if err != nil {
+			return err
+		}
+		cutoff := time.Now().Add(-pruneIdle).UnixMilli()
+		pruned := 0
+		for _, s := range servers {
+			rest, err := restClient(&s)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", s.Name, err)
+				continue
+			}
+			sessions, err := rest.ListSessions()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", s.Name, err)
+				continue
+			}
+			for _, sess := range sessions {
+				// Never prune a session that is mid-turn
+				if sess.Status == "busy" || sess.LastActivity > cutoff {
+					continue
+				}
+				if pruneDry {
+					fmt.Printf("%s: would delete %s (%s, last active %s)\n", s.Name, sess.ID, sess.ProjectPath, relativeTime(sess.LastActivity))
+					continue
+				}
+				if err := rest.DeleteSession(sess.ID); err != nil {
+					fmt.Fprintf(os.Stderr, "%s: %v\n", s.Name, err)
+					continue
+				}
+				fmt.Printf("%s: deleted %s (%s, last active %s)\n", s.Name, sess.ID, sess.ProjectPath, relativeTime(sess.LastActivity))
+				pruned++
+			}
+		}
+		if !pruneDry {
+			fmt.Printf("Pruned %d session(s)\n", pruned)
+		}
+		return nil
+	},
+}
+
+var sessionsExportCmd = &cobra.Command{
+	Use:   "export [id]",
+	Short: "Export a session as Markdown, HTML or JSON",
+	Long: `Export a session as Markdown, HTML or JSON.
+
+Messages come from the server; tool calls, permission decisions and failed
+turns come from the local transcript. If the server no longer has the
+session, the transcript alone is exported.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if !slices.Contains(export.Formats, exportFormat) {
+			return fmt.Errorf("--format must be md, html or json")
+		}
+		cmd.SilenceUsage = true
+		srv, err := cfg.FindServer(serverName)
+		if err != nil {
+			return err
+		}
+		rest, err := restClient(srv)
+		if err != nil {
+			return err
+		}
+		s, fallback, err := export.Load(rest, transcript.DefaultDir(), srv.Name, args[0], exportSince)
+		if err != nil {
+			return err
+		}
+		if fallback != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v; exporting the local transcript only\n", fallback)
+		}
+
+		if exportOutput == "" || exportOutput == "-" {
+			return export.Write(os.Stdout, s, exportFormat)
+		}
+		f, err := os.Create(exportOutput)
+		if err != nil {
+			return err
+		}
+		if err := export.Write(f, s, exportFormat); err != nil {
+			f.Close()
+			return err
+		}
+		if err := f.Close(); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Exported %d entries to %s\n", len(s.Entries), exportOutput)
+		return nil
+	},
+}
+
+// targetServers returns every configured server with --all-servers, otherwise the selected one.
+func targetServers() ([]config.Server, error) {
+	if allServers {
+		if len(cfg.Servers) == 0 {
+			return nil, fmt.Errorf("no servers configured")
+		}
+		return cfg.Servers, nil
+	}
+	srv, err := cfg.FindServer(serverName)
+	if err != nil {
+		return nil, err
+	}
+	return []config.Server{*srv}, nil
+}
+
+func init() {
+	sessionsListCmd.Flags().BoolVar(&allServers, "all-servers", false, "query every configured server")
+	sessionsPruneCmd.Flags().BoolVar(&allServers, "all-servers", false, "prune on every configured server")
+	sessionsPruneCmd.Flags().DurationVar(&pruneIdle, "idle", 2*time.Hour, "delete sessions idle for longer than this")
+	sessionsPruneCmd.Flags().BoolVar(&pruneDry, "dry-run", false, "only print what would be deleted")
+	sessionsExportCmd.Flags().StringVar(&exportFormat, "format", "md", "output format: md, html or json")
+	sessionsExportCmd.Flags().IntVar(&exportSince, "since", 0, "only export messages after this seq")
+	sessionsExportCmd.Flags().StringVarP(&exportOutput, "output", "o", "", "write to a file instead of stdout")
+
+	sessionsCmd.AddCommand(sessionsListCmd)
+	sessionsCmd.AddCommand(sessionsShowCmd)
+	sessionsCmd.AddCommand(sessionsDeleteCmd)
+	sessionsCmd.AddCommand(sessionsPruneCmd)
+	sessionsCmd.AddCommand(sessionsExportCmd)
+	rootCmd.AddCommand(sessionsCmd)
+}
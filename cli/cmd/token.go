@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/arvid/remote-ai-ide/cli/internal/client"
+	"github.com/arvid/remote-ai-ide/cli/internal/config"
+	"github.com/arvid/remote-ai-ide/cli/internal/secret"
+)
+
+var (
+	secrets    secret.Store
+	tokenCache = map[string]string{}
+)
+
+// secretStore opens the secret backend on first use, so commands that
+// never need a secret never touch the keyring or prompt.
+func secretStore() secret.Store {
+	if secrets == nil {
+		secrets = secret.Open(secretsPath())
+	}
+	return secrets
+}
+
+// secretsPath puts the encrypted fallback file beside the config file.
+func secretsPath() string {
+	return strings.TrimSuffix(cfgFile, filepath.Ext(cfgFile)) + ".secrets.age"
+}
+
+// serverToken resolves srv's token, following env:, cmd: and secret:
+// references. Results are cached so a command runs or prompts only once.
+func serverToken(srv *config.Server) (string, error) {
+	if t, ok := tokenCache[srv.Name]; ok {
+		return t, nil
+	}
+	t, err := secret.Resolve(srv.Token, secretStore)
+	if err != nil {
+		return "", fmt.Errorf("token for server %q: %w", srv.Name, err)
+	}
+	tokenCache[srv.Name] = t
+	return t, nil
+}
+
+// restClient builds a REST client for srv with its resolved token.
+func restClient(srv *config.Server) (*client.RESTClient, error) {
+	token, err := serverToken(srv)
+	if err != nil {
+		return nil, err
+	}
+	return client.NewRESTClient(srv.URL, token, clientOptions()...), nil
+}
+
+// connOptions adds srv's heartbeat and reconnect settings to the client
+// options.
+func connOptions(srv *config.Server) []client.Option {
+	return append(clientOptions(),
+		client.WithHeartbeat(srv.PingInterval, srv.PongTimeout),
+		client.WithReconnect(client.ReconnectPolicy{
+			Disabled:    srv.Reconnect != nil && !*srv.Reconnect,
+			MaxAttempts: srv.MaxAttempts,
+			MaxDelay:    srv.MaxDelay,
+		}))
+}
@@ -0,0 +1,520 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// ErrClosed is returned by Send once the connection has been closed or has
+// given up reconnecting.
+var ErrClosed = errors.New("connection closed")
+
+// ErrNotSent is returned by Send for a user message that had not gone out
+// when the connection was lost. It is not kept for the next connection:
+// the caller sends it again once it has caught up on the session.
+var ErrNotSent = errors.New("not sent: connection lost")
+
+// Reconnecting is emitted before each redial attempt.
+type Reconnecting struct {
+	Attempt     int
+	MaxAttempts int
+	Err         error // why the previous connection or attempt failed
+}
+
+// Reconnected is emitted once a redial succeeds. Frames the server sent
+// while the connection was down are lost and must be fetched over REST.
+type Reconnected struct{}
+
+// QueueChanged is emitted when the number of frames held back by the
+// client-side rate limit, or waiting for a connection, changes.
+type QueueChanged struct {
+	Depth int
+}
+
+// Disconnected is the last event before Events is closed when the
+// connection was lost for good. Closing the Conn does not emit it.
+type Disconnected struct {
+	Err error
+}
+
+// BadFrame carries a frame from the server that could not be decoded.
+type BadFrame struct {
+	Data []byte
+	Err  error
+}
+
+// Rejected is emitted when the client gives up on a user message the
+// server's rate limit turned away: it was refused maxRetries more times,
+// or, with Lost set, the connection dropped while it waited to go again.
+// Message is as it was sent, so a resend can keep its Seq.
+type Rejected struct {
+	Message UserMessage
+	Lost    bool
+}
+
+func (Reconnecting) event() {}
+func (Reconnected) event()  {}
+func (QueueChanged) event() {}
+func (Disconnected) event() {}
+func (BadFrame) event()     {}
+func (Rejected) event()     {}
+
+// Recorder is called with every frame written to or read from the server.
+// out is true for frames the client sent.
+type Recorder func(out bool, data []byte)
+
+// Conn is a WebSocket connection to a server. It redials when the
+// connection drops, keeps under the server's rate limit, and delivers
+// server frames and connection changes, in order, on Events.
+type Conn struct {
+	url       string
+	token     string // only kept to redact it from logs
+	log       *slog.Logger
+	logFrames bool
+
+	pingInterval time.Duration
+	pongTimeout  time.Duration
+	redial       ReconnectPolicy
+
+	wmu sync.Mutex // serialises writes; taken before mu, never inside it
+
+	mu       sync.Mutex      // guards the state below, never held across I/O
+	conn     *websocket.Conn // nil while redialing
+	recorder Recorder
+	closed   bool
+	limit    window
+	queue    []*outFrame // held back by the rate limit or a redial
+	inflight *outFrame   // written, but not yet past the server's limit
+	seqs     map[string]*seqState
+
+	events    chan Event
+	wake      chan struct{}
+	ctx       context.Context // cancelled on Close or when giving up
+	cancel    context.CancelFunc
+	readDone  chan struct{}
+	writeDone chan struct{}
+}
+
+// CloseUnauthorized is the close code the server sends when the token
+// given on /ws is missing or wrong.
+const CloseUnauthorized = 4001
+
+// WSURL builds the WebSocket endpoint for a server URL; the token travels
+// as a query parameter since browsers cannot set headers on upgrades.
+func WSURL(baseURL, token string) (string, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return "", err
+	}
+	scheme := "ws"
+	if u.Scheme == "https" {
+		scheme = "wss"
+	}
+	return fmt.Sprintf("%s://%s/ws?token=%s", scheme, u.Host, url.QueryEscape(token)), nil
+}
+
+// Dial connects to the server at baseURL. ctx bounds the first dial only;
+// use Close to end the connection.
+func Dial(ctx context.Context, baseURL, token string, opts ...Option) (*Conn, error) {
+	wsURL, err := WSURL(baseURL, token)
+	if err != nil {
+		return nil, err
+	}
+
+	o := buildOptions(opts)
+	c := &Conn{
+		url:          wsURL,
+		token:        token,
+		log:          o.logger.With("component", "ws"),
+		logFrames:    o.frames,
+		pingInterval: o.pingInterval,
+		pongTimeout:  o.pongTimeout,
+		redial:       o.redial,
+		seqs:         make(map[string]*seqState),
+		events:       make(chan Event, 100),
+		wake:         make(chan struct{}, 1),
+		readDone:     make(chan struct{}),
+		writeDone:    make(chan struct{}),
+	}
+	c.ctx, c.cancel = context.WithCancel(context.Background())
+	if err := c.connect(ctx); err != nil {
+		c.cancel()
+		return nil, err
+	}
+	go c.readLoop()
+	go c.writeLoop()
+	go c.pingLoop()
+	return c, nil
+}
+
+// Events delivers server frames, as *AssistantChunk, *AssistantMessageMsg,
+// *PermissionRequest, *ToolEvent, *SessionState or *ResultMessage, along
+// with connection changes and Rejected user messages. Repeated frames are
+// dropped, and missing ones are reported as SeqGap. It is closed once the
+// connection has ended.
+func (c *Conn) Events() <-chan Event {
+	return c.events
+}
+
+func (c *Conn) connect(ctx context.Context) error {
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, c.url, nil)
+	if err != nil {
+		c.log.Warn("connect failed", "url", c.BaseURL(), "err", err)
+		return fmt.Errorf("ws connect: %w", err)
+	}
+	c.watch(conn)
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		conn.Close()
+		return ErrClosed
+	}
+	c.conn = conn
+	// The server's count is per socket, and whatever was in flight on the
+	// old one is lost with it.
+	c.limit.reset()
+	c.inflight = nil
+	c.mu.Unlock()
+	c.log.Info("connected", "url", c.BaseURL())
+	c.wakeWriter()
+	return nil
+}
+
+func (c *Conn) readLoop() {
+	defer close(c.readDone)
+	var lost error
+	for {
+		c.mu.Lock()
+		conn := c.conn
+		c.mu.Unlock()
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			c.mu.Lock()
+			closed := c.closed
+			if c.conn == conn {
+				c.conn = nil
+			}
+			stranded := c.unqueueUserMessages()
+			c.mu.Unlock()
+			if closed {
+				break
+			}
+			for _, f := range stranded {
+				c.emit(rejected(f, true))
+			}
+			conn.Close()
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				err = fmt.Errorf("no answer from the server for %s", c.pingInterval+c.pongTimeout)
+			}
+			c.log.Warn("connection lost", "err", err)
+			if err = c.reconnect(err); err == nil {
+				continue
+			}
+			if c.ctx.Err() == nil {
+				if !c.redial.Disabled {
+					c.log.Error("giving up reconnecting", "attempts", c.redial.MaxAttempts)
+				}
+				lost = err
+			}
+			break
+		}
+		c.extendDeadline(conn)
+		c.logFrame("in", data)
+		c.record(false, data)
+		if c.track(data) {
+			continue
+		}
+		ev, err := ParseServerMessage(data)
+		if err != nil {
+			c.emit(BadFrame{Data: data, Err: err})
+			continue
+		}
+		c.mu.Lock()
+		fresh, gap := c.sequence(ev)
+		c.mu.Unlock()
+		if gap != nil {
+			c.log.Warn("frames missing", "session", gap.SessionID, "from", gap.From, "to", gap.To)
+			c.emit(*gap)
+		}
+		if !fresh {
+			c.log.Debug("dropped duplicate frame", "session", FrameSessionID(ev), "type", fmt.Sprintf("%T", ev))
+			continue
+		}
+		c.emit(ev)
+	}
+
+	if lost != nil {
+		c.emit(Disconnected{Err: lost})
+	}
+	c.shutdown()
+	<-c.writeDone
+	close(c.events)
+}
+
+// emit delivers ev unless the connection is shutting down.
+func (c *Conn) emit(ev Event) bool {
+	select {
+	case c.events <- ev:
+		return true
+	case <-c.ctx.Done():
+		return false
+	}
+}
+
+// SetRecorder installs r to observe all traffic from now on.
+func (c *Conn) SetRecorder(r Recorder) {
+	c.mu.Lock()
+	c.recorder = r
+	c.mu.Unlock()
+}
+
+func (c *Conn) logFrame(dir string, data []byte) {
+	if c.logFrames {
+		c.log.Debug("frame", "dir", dir, "data", redact(string(data), c.token))
+	}
+}
+
+func (c *Conn) record(out bool, data []byte) {
+	c.mu.Lock()
+	r := c.recorder
+	c.mu.Unlock()
+	if r != nil {
+		r(out, data)
+	}
+}
+
+// Send writes m to the server and returns any write error. If the rate
+// limit holds m back, or the connection is being re-established, m is
+// queued and Send waits until it is written, ctx is done or the connection
+// closes. A frame also waits while the one before it is still unconfirmed
+// by the server, so a rejection has one owner and that frame is retried.
+// Permission answers and interrupts are queued ahead of the rest.
+// User messages are the exception to waiting out a redial: they return
+// ErrNotSent, so that none reaches a new connection before its owner has
+// switched to the session and fetched what it missed. A user message
+// without a Seq is given the session's next client seq.
+func (c *Conn) Send(ctx context.Context, m ClientMessage) error {
+	c.mu.Lock()
+	m = c.number(m)
+	c.mu.Unlock()
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	f := newOutFrame(data)
+	c.mu.Lock()
+	if c.closed || c.ctx.Err() != nil {
+		c.mu.Unlock()
+		return ErrClosed
+	}
+	if c.conn == nil && f.kind == "user_message" {
+		c.mu.Unlock()
+		return ErrNotSent
+	}
+	// Go straight out only if no other write is under way; otherwise the
+	// frame waits its turn in the queue, where ctx still applies.
+	if c.canSendNow(f) && c.wmu.TryLock() {
+		conn := c.conn
+		c.claim(f)
+		c.mu.Unlock()
+		deadline := time.Now().Add(writeTimeout)
+		if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+			deadline = d
+		}
+		err = c.write(conn, f, deadline)
+		c.wmu.Unlock()
+		if err != nil {
+			c.mu.Lock()
+			c.unclaim(f)
+			c.mu.Unlock()
+			c.log.Warn("send failed", "err", err)
+			if f.kind == "user_message" {
+				err = fmt.Errorf("%w: %w", ErrNotSent, err)
+			}
+			return fmt.Errorf("sending %s: %w", f.kind, err)
+		}
+		c.logFrame("out", data)
+		c.record(true, data)
+		return nil
+	}
+
+	done := make(chan error, 1)
+	f.done = done
+	c.enqueue(f, false)
+	depth := len(c.queue)
+	c.mu.Unlock()
+	c.log.Info("queued frame", "type", f.kind, "depth", depth)
+	c.wakeWriter()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		c.mu.Lock()
+		withdrawn := c.dequeue(f)
+		if !withdrawn {
+			f.abandoned = true
+		}
+		c.mu.Unlock()
+		if !withdrawn {
+			// Being written, or done already; the write has a deadline
+			return <-done
+		}
+		c.wakeWriter()
+		return ctx.Err()
+	}
+}
+
+// Close sends a close frame and waits, until ctx is done, for the server
+// to acknowledge it before dropping the connection. Queued frames are
+// dropped and their Send calls return ErrClosed.
+func (c *Conn) Close(ctx context.Context) error {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		<-c.readDone
+		return nil
+	}
+	c.closed = true
+	conn := c.conn
+	c.mu.Unlock()
+	var err error
+	if conn != nil {
+		deadline := time.Now().Add(writeTimeout)
+		if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+			deadline = d
+		}
+		err = conn.WriteControl(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), deadline)
+	}
+	c.shutdown()
+
+	if conn != nil {
+		if err == nil {
+			select {
+			case <-c.readDone:
+			case <-ctx.Done():
+				err = ctx.Err()
+			}
+		}
+		conn.Close()
+	}
+	<-c.readDone
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+	return nil
+}
+
+// unqueueUserMessages fails the queued user messages with ErrNotSent once
+// the connection is lost, and returns those waiting to be retried, whose
+// Send has returned already. Must hold c.mu.
+func (c *Conn) unqueueUserMessages() []*outFrame {
+	var retries []*outFrame
+	kept := c.queue[:0]
+	for _, f := range c.queue {
+		if f.kind == "user_message" {
+			if f.retries > 0 {
+				retries = append(retries, f)
+			}
+			f.finish(ErrNotSent)
+			continue
+		}
+		kept = append(kept, f)
+	}
+	if n := len(c.queue) - len(kept); n > 0 {
+		c.log.Info("user messages not sent before the connection was lost", "count", n)
+	}
+	clear(c.queue[len(kept):])
+	c.queue = kept
+	return retries
+}
+
+// shutdown stops the write loop and any redial, and fails queued sends.
+func (c *Conn) shutdown() {
+	c.cancel()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if n := len(c.queue); n > 0 {
+		c.log.Warn("dropping queued frames", "count", n)
+	}
+	for _, f := range c.queue {
+		f.finish(ErrClosed)
+	}
+	c.queue = nil
+}
+
+// ParseServerMessage decodes a server frame into one of the server message
+// types, which keeps data as its Raw frame.
+func ParseServerMessage(data []byte) (Event, error) {
+	var base ServerMessage
+	if err := json.Unmarshal(data, &base); err != nil {
+		return nil, err
+	}
+	var ev Event
+	switch base.Type {
+	case "assistant_chunk":
+		ev = &AssistantChunk{}
+	case "assistant_message":
+		ev = &AssistantMessageMsg{}
+	case "permission_request":
+		ev = &PermissionRequest{}
+	case "tool_event":
+		ev = &ToolEvent{}
+	case "session_state":
+		ev = &SessionState{}
+	case "result":
+		ev = &ResultMessage{}
+	default:
+		return nil, fmt.Errorf("unknown message type: %s", base.Type)
+	}
+	if err := json.Unmarshal(data, ev); err != nil {
+		return nil, err
+	}
+	ev.(interface{ setRaw([]byte) }).setRaw(data)
+	return ev, nil
+}
+
+// FrameSessionID returns the session a server frame belongs to. Rate-limit
+// and parse errors carry an empty session ID and apply to every session.
+func FrameSessionID(ev Event) string {
+	switch m := ev.(type) {
+	case *AssistantChunk:
+		return m.SessionID
+	case *AssistantMessageMsg:
+		return m.SessionID
+	case *PermissionRequest:
+		return m.SessionID
+	case *ToolEvent:
+		return m.SessionID
+	case *SessionState:
+		return m.SessionID
+	case *ResultMessage:
+		return m.SessionID
+	}
+	return ""
+}
+
+// BaseURL extracts the original base URL (for display)
+func (c *Conn) BaseURL() string {
+	u, err := url.Parse(c.url)
+	if err != nil {
+		return c.url
+	}
+	scheme := "http"
+	if strings.HasPrefix(u.Scheme, "wss") {
+		scheme = "https"
+	}
+	return fmt.Sprintf("%s://%s", scheme, u.Host)
+}
@@ -0,0 +1,393 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// wsServer upgrades /ws and lets a test drop its connections, refuse new
+// ones to keep a client redialing, or leave pings unanswered.
+type wsServer struct {
+	*httptest.Server
+	received chan []byte
+
+	mu      sync.Mutex
+	conns   []*websocket.Conn
+	refuse  bool
+	deaf    int // how many of the next connections ignore pings
+	upgrade int
+}
+
+func newWSServer(t *testing.T) *wsServer {
+	s := &wsServer{received: make(chan []byte, 100)}
+	upgrader := websocket.Upgrader{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/ws" {
+			http.NotFound(w, r)
+			return
+		}
+		s.mu.Lock()
+		refuse := s.refuse
+		s.mu.Unlock()
+		if refuse {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.conns = append(s.conns, conn)
+		s.upgrade++
+		if s.deaf > 0 {
+			s.deaf--
+			conn.SetPingHandler(func(string) error { return nil })
+		}
+		s.mu.Unlock()
+		go func() {
+			for {
+				_, data, err := conn.ReadMessage()
+				if err != nil {
+					return
+				}
+				s.received <- data
+			}
+		}()
+	}))
+	t.Cleanup(func() {
+		s.drop()
+		s.Close()
+	})
+	return s
+}
+
+// drop cuts every open connection without a close frame, as a network
+// failure would.
+func (s *wsServer) drop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, conn := range s.conns {
+		conn.NetConn().Close()
+	}
+	s.conns = nil
+}
+
+func (s *wsServer) setRefuse(on bool) {
+	s.mu.Lock()
+	s.refuse = on
+	s.mu.Unlock()
+}
+
+// ignorePings leaves pings unanswered on the next n connections, as a
+// half-open connection would.
+func (s *wsServer) ignorePings(n int) {
+	s.mu.Lock()
+	s.deaf = n
+	s.mu.Unlock()
+}
+
+func (s *wsServer) upgrades() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.upgrade
+}
+
+// nextEvent returns the next connection event, skipping queue depth and
+// latency reports, or nil once Events is closed.
+func nextEvent(t *testing.T, c *Conn, timeout time.Duration) Event {
+	t.Helper()
+	deadline := time.After(timeout)
+	for {
+		select {
+		case ev, ok := <-c.Events():
+			if !ok {
+				return nil
+			}
+			switch ev.(type) {
+			case QueueChanged, Latency:
+				continue
+			}
+			return ev
+		case <-deadline:
+			t.Fatalf("no event within %s", timeout)
+			return nil
+		}
+	}
+}
+
+func dialTest(t *testing.T, s *wsServer, p ReconnectPolicy, opts ...Option) *Conn {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	c, err := Dial(ctx, s.URL, "t", append(opts, WithReconnect(p))...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		c.Close(ctx)
+	})
+	return c
+}
+
+func TestConnRedials(t *testing.T) {
+	s := newWSServer(t)
+	c := dialTest(t, s, ReconnectPolicy{MaxAttempts: 3, MaxDelay: 20 * time.Millisecond})
+
+	s.drop()
+	ev := nextEvent(t, c, 5*time.Second)
+	r, ok := ev.(Reconnecting)
+	if !ok {
+		t.Fatalf("got %T after the drop, want Reconnecting", ev)
+	}
+	if r.Attempt != 1 || r.MaxAttempts != 3 || r.Err == nil {
+		t.Errorf("got %+v, want attempt 1 of 3 with the cause", r)
+	}
+	if ev := nextEvent(t, c, 5*time.Second); ev != (Reconnected{}) {
+		t.Fatalf("got %T, want Reconnected", ev)
+	}
+	if n := s.upgrades(); n != 2 {
+		t.Errorf("server saw %d upgrades, want 2", n)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := c.Send(ctx, UserMessage{Type: "user_message", SessionID: "s", Text: "hi"}); err != nil {
+		t.Fatalf("Send after reconnecting: %v", err)
+	}
+	select {
+	case <-s.received:
+	case <-ctx.Done():
+		t.Fatal("the server did not get the frame sent after reconnecting")
+	}
+}
+
+func TestConnCloseDuringBackoff(t *testing.T) {
+	s := newWSServer(t)
+	// The default delay cap, so the client sits in a wait of a second or
+	// more once the first redial has been refused.
+	c := dialTest(t, s, ReconnectPolicy{MaxAttempts: 5})
+
+	s.setRefuse(true)
+	s.drop()
+	for attempt := 1; attempt <= 2; attempt++ {
+		ev := nextEvent(t, c, 5*time.Second)
+		if r, ok := ev.(Reconnecting); !ok || r.Attempt != attempt {
+			t.Fatalf("got %#v, want Reconnecting attempt %d", ev, attempt)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	start := time.Now()
+	if err := c.Close(ctx); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if d := time.Since(start); d > 250*time.Millisecond {
+		t.Errorf("Close took %s during the backoff", d)
+	}
+	for ev := range c.Events() {
+		if _, ok := ev.(Disconnected); ok {
+			t.Error("Close emitted Disconnected")
+		}
+	}
+	if err := c.Send(ctx, UserMessage{Type: "user_message", SessionID: "s", Text: "hi"}); !errors.Is(err, ErrClosed) {
+		t.Errorf("Send after Close = %v, want ErrClosed", err)
+	}
+}
+
+func TestConnGivesUp(t *testing.T) {
+	s := newWSServer(t)
+	c := dialTest(t, s, ReconnectPolicy{MaxAttempts: 2, MaxDelay: 20 * time.Millisecond})
+
+	s.setRefuse(true)
+	s.drop()
+	for attempt := 1; attempt <= 2; attempt++ {
+		ev := nextEvent(t, c, 5*time.Second)
+		if r, ok := ev.(Reconnecting); !ok || r.Attempt != attempt || r.MaxAttempts != 2 {
+			t.Fatalf("got %#v, want Reconnecting attempt %d of 2", ev, attempt)
+		}
+	}
+	ev := nextEvent(t, c, 5*time.Second)
+	d, ok := ev.(Disconnected)
+	if !ok {
+		t.Fatalf("got %#v after the last attempt, want Disconnected", ev)
+	}
+	if d.Err == nil {
+		t.Error("Disconnected carries no error")
+	}
+	if ev := nextEvent(t, c, 5*time.Second); ev != nil {
+		t.Errorf("got %#v after Disconnected, want Events closed", ev)
+	}
+	if n := s.upgrades(); n != 1 {
+		t.Errorf("server saw %d upgrades, want 1", n)
+	}
+}
+
+func TestConnReconnectDisabled(t *testing.T) {
+	s := newWSServer(t)
+	c := dialTest(t, s, ReconnectPolicy{Disabled: true})
+
+	s.drop()
+	ev := nextEvent(t, c, 5*time.Second)
+	if _, ok := ev.(Disconnected); !ok {
+		t.Fatalf("got %#v after the drop, want Disconnected", ev)
+	}
+	if ev := nextEvent(t, c, 5*time.Second); ev != nil {
+		t.Errorf("got %#v after Disconnected, want Events closed", ev)
+	}
+	if n := s.upgrades(); n != 1 {
+		t.Errorf("server saw %d upgrades, want 1", n)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := c.Send(ctx, UserMessage{Type: "user_message", SessionID: "s", Text: "hi"}); !errors.Is(err, ErrClosed) {
+		t.Errorf("Send after Disconnected = %v, want ErrClosed", err)
+	}
+}
+
+func TestConnPongTimeout(t *testing.T) {
+	t.Run("redials", func(t *testing.T) {
+		s := newWSServer(t)
+		s.ignorePings(1)
+		c := dialTest(t, s, ReconnectPolicy{MaxAttempts: 3, MaxDelay: 20 * time.Millisecond},
+			WithHeartbeat(50*time.Millisecond, 100*time.Millisecond))
+
+		ev := nextEvent(t, c, 5*time.Second)
+		r, ok := ev.(Reconnecting)
+		if !ok {
+			t.Fatalf("got %#v from a connection that ignores pings, want Reconnecting", ev)
+		}
+		if r.Err == nil || !strings.Contains(r.Err.Error(), "no answer from the server") {
+			t.Errorf("Reconnecting.Err = %v, want the missing answer", r.Err)
+		}
+		if ev := nextEvent(t, c, 5*time.Second); ev != (Reconnected{}) {
+			t.Fatalf("got %#v, want Reconnected", ev)
+		}
+		// The new connection answers, so it stays up past the timeout
+		select {
+		case ev := <-c.Events():
+			if _, ok := ev.(Latency); !ok {
+				t.Errorf("got %#v on a live connection, want Latency", ev)
+			}
+		case <-time.After(time.Second):
+			t.Error("no pong on the new connection")
+		}
+		time.Sleep(300 * time.Millisecond)
+		if n := s.upgrades(); n != 2 {
+			t.Errorf("server saw %d upgrades, want 2", n)
+		}
+	})
+
+	t.Run("disconnects", func(t *testing.T) {
+		s := newWSServer(t)
+		s.ignorePings(1)
+		c := dialTest(t, s, ReconnectPolicy{Disabled: true},
+			WithHeartbeat(50*time.Millisecond, 100*time.Millisecond))
+
+		ev := nextEvent(t, c, 5*time.Second)
+		d, ok := ev.(Disconnected)
+		if !ok {
+			t.Fatalf("got %#v from a connection that ignores pings, want Disconnected", ev)
+		}
+		if d.Err == nil || !strings.Contains(d.Err.Error(), "no answer from the server") {
+			t.Errorf("Disconnected.Err = %v, want the missing answer", d.Err)
+		}
+	})
+}
+
+func TestConnUserMessageNotKeptAcrossRedial(t *testing.T) {
+	s := newWSServer(t)
+	c := dialTest(t, s, ReconnectPolicy{MaxAttempts: 5, MaxDelay: 50 * time.Millisecond})
+
+	s.setRefuse(true)
+	s.drop()
+	if ev := nextEvent(t, c, 5*time.Second); ev == nil {
+		t.Fatal("Events closed after the drop")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := c.Send(ctx, NewUserMessage("s", "hi")); !errors.Is(err, ErrNotSent) {
+		t.Errorf("user message Send while redialing = %v, want ErrNotSent", err)
+	}
+
+	// Other frames wait for the next connection
+	sent := make(chan error, 1)
+	go func() { sent <- c.Send(ctx, NewInterrupt("s")) }()
+	s.setRefuse(false)
+	select {
+	case err := <-sent:
+		if err != nil {
+			t.Fatalf("interrupt Send across the redial: %v", err)
+		}
+	case <-ctx.Done():
+		t.Fatal("the interrupt was not sent after reconnecting")
+	}
+	select {
+	case data := <-s.received:
+		if !strings.Contains(string(data), `"interrupt"`) {
+			t.Errorf("server got %s first, want the interrupt", data)
+		}
+	case <-ctx.Done():
+		t.Fatal("the server did not get the interrupt")
+	}
+}
+
+func TestConnOneFrameInFlight(t *testing.T) {
+	s := newWSServer(t)
+	s.ignorePings(1)
+	c := dialTest(t, s, ReconnectPolicy{Disabled: true})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := c.Send(ctx, NewUserMessage("s", "first")); err != nil {
+		t.Fatalf("first Send: %v", err)
+	}
+	// Without the pong to its fence the first frame stays in flight, so
+	// the next one waits rather than share the blame for a rejection
+	short, cancelShort := context.WithTimeout(ctx, 200*time.Millisecond)
+	defer cancelShort()
+	if err := c.Send(short, NewInterrupt("s")); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Send behind an unsettled frame = %v, want it held until ctx ended", err)
+	}
+	<-s.received
+	select {
+	case data := <-s.received:
+		t.Errorf("server got %s while the first frame was in flight", data)
+	default:
+	}
+}
+
+func TestConnSendsAfterFence(t *testing.T) {
+	s := newWSServer(t)
+	c := dialTest(t, s, ReconnectPolicy{Disabled: true})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for _, text := range []string{"one", "two", "three"} {
+		if err := c.Send(ctx, NewUserMessage("s", text)); err != nil {
+			t.Fatalf("Send %q: %v", text, err)
+		}
+	}
+	for _, text := range []string{"one", "two", "three"} {
+		select {
+		case data := <-s.received:
+			if !strings.Contains(string(data), `"`+text+`"`) {
+				t.Errorf("server got %s, want %q", data, text)
+			}
+		case <-ctx.Done():
+			t.Fatalf("server did not get %q", text)
+		}
+	}
+}
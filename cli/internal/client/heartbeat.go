@@ -0,0 +1,73 @@
+package client
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	defaultPingInterval = 15 * time.Second
+	defaultPongTimeout  = 10 * time.Second
+	// writeTimeout bounds every write, so sending on a dead connection
+	// fails instead of blocking until the kernel gives up.
+	writeTimeout = 10 * time.Second
+)
+
+// Latency reports the round trip of the last ping.
+type Latency struct {
+	RTT time.Duration
+}
+
+func (Latency) event() {}
+
+// watch arms the heartbeat on a new connection. Every frame or pong from
+// the server pushes the read deadline out, so a connection that stays
+// silent for a ping interval plus the pong timeout, as a half-open one
+// does, fails its read and takes the reconnect path. A pong may also
+// settle the frame in flight.
+func (c *Conn) watch(conn *websocket.Conn) {
+	c.extendDeadline(conn)
+	conn.SetPongHandler(func(data string) error {
+		c.extendDeadline(conn)
+		c.settle(data)
+		if sent, err := strconv.ParseInt(data, 10, 64); err == nil {
+			rtt := time.Since(time.Unix(0, sent))
+			c.log.Debug("pong", "rtt", rtt)
+			c.emit(Latency{RTT: rtt})
+		}
+		return nil
+	})
+}
+
+func (c *Conn) extendDeadline(conn *websocket.Conn) {
+	conn.SetReadDeadline(time.Now().Add(c.pingInterval + c.pongTimeout))
+}
+
+// pingLoop pings the server every ping interval, carrying the send time
+// so the pong tells the round trip.
+func (c *Conn) pingLoop() {
+	ticker := time.NewTicker(c.pingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-c.ctx.Done():
+			return
+		}
+		c.mu.Lock()
+		conn := c.conn
+		c.mu.Unlock()
+		if conn == nil {
+			continue
+		}
+		now := time.Now()
+		payload := []byte(strconv.FormatInt(now.UnixNano(), 10))
+		if err := conn.WriteControl(websocket.PingMessage, payload, now.Add(writeTimeout)); err != nil {
+			c.log.Warn("ping failed", "err", err)
+			// Fails the read too, so readLoop redials
+			conn.Close()
+		}
+	}
+}
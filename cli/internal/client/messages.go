@@ -2,6 +2,18 @@ package client
 
 import "encoding/json"
 
+// ClientMessage is a frame the client sends; build one with the New*
+// constructors below.
+type ClientMessage interface {
+	clientMessage()
+}
+
+// Event is received on Conn.Events: a server frame or a change in the
+// connection. The set of events is closed; switch on the concrete types.
+type Event interface {
+	event()
+}
+
 // Client -> Server
 type UserMessage struct {
 	Type      string `json:"type"`
@@ -32,6 +44,12 @@ type ResetSessionMsg struct {
 	SessionID string `json:"sessionId"`
 }
 
+func (UserMessage) clientMessage()           {}
+func (PermissionResponseMsg) clientMessage() {}
+func (InterruptMessage) clientMessage()      {}
+func (SwitchSessionMsg) clientMessage()      {}
+func (ResetSessionMsg) clientMessage()       {}
+
 func NewUserMessage(sessionID, text string) UserMessage {
 	return UserMessage{Type: "user_message", SessionID: sessionID, Text: text}
 }
@@ -44,12 +62,17 @@ func NewInterrupt(sessionID string) InterruptMessage {
 	return InterruptMessage{Type: "interrupt", SessionID: sessionID}
 }
 
+func NewSwitchSession(sessionID string) SwitchSessionMsg {
+	return SwitchSessionMsg{Type: "switch_session", SessionID: sessionID}
+}
+
 func NewResetSession(sessionID string) ResetSessionMsg {
 	return ResetSessionMsg{Type: "reset_session", SessionID: sessionID}
 }
 
 // Server -> Client
 type AssistantChunk struct {
+	RawFrame
 	Type      string `json:"type"`
 	SessionID string `json:"sessionId"`
 	Content   string `json:"content"`
@@ -57,6 +80,7 @@ type AssistantChunk struct {
 }
 
 type AssistantMessageMsg struct {
+	RawFrame
 	Type      string `json:"type"`
 	SessionID string `json:"sessionId"`
 	Content   string `json:"content"`
@@ -64,6 +88,7 @@ type AssistantMessageMsg struct {
 }
 
 type PermissionRequest struct {
+	RawFrame
 	Type        string          `json:"type"`
 	SessionID   string          `json:"sessionId"`
 	RequestID   string          `json:"requestId"`
@@ -72,7 +97,17 @@ type PermissionRequest struct {
 	Description string          `json:"description"`
 }
 
+type ToolEvent struct {
+	RawFrame
+	Type      string          `json:"type"`
+	SessionID string          `json:"sessionId"`
+	ToolName  string          `json:"toolName"`
+	ToolInput json.RawMessage `json:"toolInput"`
+	Seq       int             `json:"seq"`
+}
+
 type SessionState struct {
+	RawFrame
 	Type         string `json:"type"`
 	SessionID    string `json:"sessionId"`
 	Status       string `json:"status"`
@@ -80,6 +115,7 @@ type SessionState struct {
 }
 
 type ResultMessage struct {
+	RawFrame
 	Type      string `json:"type"`
 	SessionID string `json:"sessionId"`
 	Success   bool   `json:"success"`
@@ -87,6 +123,24 @@ type ResultMessage struct {
 	Seq       int    `json:"seq"`
 }
 
+// RawFrame is embedded in the server messages and keeps the frame each was
+// parsed from, fields the client does not know included.
+type RawFrame struct {
+	raw json.RawMessage
+}
+
+// Raw returns the frame as the server sent it.
+func (f RawFrame) Raw() json.RawMessage { return f.raw }
+
+func (f *RawFrame) setRaw(data []byte) { f.raw = data }
+
+func (AssistantChunk) event()      {}
+func (AssistantMessageMsg) event() {}
+func (PermissionRequest) event()   {}
+func (ToolEvent) event()           {}
+func (SessionState) event()        {}
+func (ResultMessage) event()       {}
+
 type ServerMessage struct {
 	Type string `json:"type"`
 }
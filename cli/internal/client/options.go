@@ -0,0 +1,83 @@
+package client
+
+import (
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// Option configures a RESTClient or Conn.
+type Option func(*options)
+
+type options struct {
+	logger       *slog.Logger
+	frames       bool
+	pingInterval time.Duration
+	pongTimeout  time.Duration
+	redial       ReconnectPolicy
+}
+
+// WithLogger sends the client's diagnostics to l. Without it nothing is
+// logged, since stderr usually belongs to the TUI.
+func WithLogger(l *slog.Logger) Option {
+	return func(o *options) {
+		if l != nil {
+			o.logger = l
+		}
+	}
+}
+
+// WithFrameLog logs every WebSocket frame at debug level, with the token
+// redacted, for debugging protocol issues.
+func WithFrameLog(on bool) Option {
+	return func(o *options) { o.frames = on }
+}
+
+// WithHeartbeat sets how often a Conn pings the server and how long it
+// waits for the pong before treating the connection as dead. Zero keeps
+// the default of a ping every 15s and a 10s timeout.
+func WithHeartbeat(interval, timeout time.Duration) Option {
+	return func(o *options) {
+		if interval > 0 {
+			o.pingInterval = interval
+		}
+		if timeout > 0 {
+			o.pongTimeout = timeout
+		}
+	}
+}
+
+// WithReconnect sets how a Conn redials after the connection drops. Zero
+// fields keep the defaults of 10 attempts and a 30s delay cap.
+func WithReconnect(p ReconnectPolicy) Option {
+	return func(o *options) {
+		o.redial.Disabled = p.Disabled
+		if p.MaxAttempts > 0 {
+			o.redial.MaxAttempts = p.MaxAttempts
+		}
+		if p.MaxDelay > 0 {
+			o.redial.MaxDelay = p.MaxDelay
+		}
+	}
+}
+
+func buildOptions(opts []Option) options {
+	o := options{
+		logger:       slog.New(slog.DiscardHandler),
+		pingInterval: defaultPingInterval,
+		pongTimeout:  defaultPongTimeout,
+		redial:       ReconnectPolicy{MaxAttempts: defaultMaxAttempts, MaxDelay: defaultMaxDelay},
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// redact hides token wherever it appears in s.
+func redact(s, token string) string {
+	if token == "" {
+		return s
+	}
+	return strings.ReplaceAll(s, token, "[REDACTED]")
+}
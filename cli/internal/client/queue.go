@@ -0,0 +1,357 @@
+package client
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// The server accepts at most rateLimit frames per socket in any ratePeriod
+// and answers the rest with a "Rate limit exceeded" result, dropping them.
+const (
+	rateLimit  = 30
+	ratePeriod = time.Minute
+	// rateMargin covers the time between writing a frame and the server
+	// counting it, so a slot is not reused before the server frees it.
+	rateMargin = 2 * time.Second
+	// rateReserve slots are kept for priority frames, so answering a
+	// permission prompt or interrupting never waits behind chat.
+	rateReserve = 3
+
+	// maxRetries bounds how often a frame rejected by the server is resent.
+	maxRetries = 3
+)
+
+// rateLimitError is the start of the error the server sends for a frame
+// over its limit.
+const rateLimitError = "Rate limit exceeded"
+
+// window mirrors the server's sliding-window limit. A token bucket that
+// refills steadily would let through frames the server's window rejects.
+type window struct {
+	sent []time.Time
+}
+
+func (w *window) prune(now time.Time) {
+	cutoff := now.Add(-(ratePeriod + rateMargin))
+	i := 0
+	for i < len(w.sent) && !w.sent[i].After(cutoff) {
+		i++
+	}
+	w.sent = w.sent[i:]
+}
+
+// wait returns how long until a frame may be sent, or 0 if it may go now.
+// Ordinary frames leave rateReserve slots free for priority ones.
+func (w *window) wait(priority bool, now time.Time) time.Duration {
+	w.prune(now)
+	limit := rateLimit - rateReserve
+	if priority {
+		limit = rateLimit
+	}
+	if len(w.sent) < limit {
+		return 0
+	}
+	return w.sent[len(w.sent)-limit].Add(ratePeriod + rateMargin).Sub(now)
+}
+
+func (w *window) record(now time.Time) {
+	w.sent = append(w.sent, now)
+}
+
+// saturate fills the window after the server rejected a frame: its count
+// is evidently ahead of ours, so nothing goes out for a full period.
+func (w *window) saturate(now time.Time) {
+	for len(w.sent) < rateLimit {
+		w.sent = append(w.sent, now)
+	}
+}
+
+// reset forgets all sends; the server counts per socket, so a new
+// connection starts with an empty window.
+func (w *window) reset() {
+	w.sent = nil
+}
+
+// outFrame is an encoded client frame waiting for, or past, the wire.
+type outFrame struct {
+	data     []byte
+	kind     string
+	priority bool
+	retries  int
+	fence    string     // payload of the ping written behind it
+	done     chan error // receives the outcome of a queued Send
+
+	// abandoned is set when its Send's ctx ended while f was being written
+	abandoned bool
+}
+
+func newOutFrame(data []byte) *outFrame {
+	var head struct {
+		Type string `json:"type"`
+	}
+	json.Unmarshal(data, &head)
+	return &outFrame{
+		data:     data,
+		kind:     head.Type,
+		priority: head.Type == "permission_response" || head.Type == "interrupt",
+	}
+}
+
+// finish reports the outcome to a Send waiting on f, once. Must hold c.mu.
+func (f *outFrame) finish(err error) {
+	if f.done != nil {
+		f.done <- err
+		f.done = nil
+	}
+}
+
+// enqueue adds f to the queue, which holds priority frames first and each
+// class in send order. Retries go ahead of the fresh frames of their class
+// so messages keep their order. Must hold c.mu.
+func (c *Conn) enqueue(f *outFrame, retry bool) {
+	i := 0
+	if !f.priority {
+		for i < len(c.queue) && c.queue[i].priority {
+			i++
+		}
+	}
+	for i < len(c.queue) && c.queue[i].priority == f.priority && (!retry || c.queue[i].retries > 0) {
+		i++
+	}
+	c.queue = append(c.queue, nil)
+	copy(c.queue[i+1:], c.queue[i:])
+	c.queue[i] = f
+}
+
+// dequeue withdraws f from the queue, reporting whether it was still
+// there. Must hold c.mu.
+func (c *Conn) dequeue(f *outFrame) bool {
+	for i, q := range c.queue {
+		if q == f {
+			c.queue = append(c.queue[:i], c.queue[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// canSendNow reports whether f may skip the queue: no frame is in
+// flight, nothing of its priority or higher is waiting and the window has
+// room. Must hold c.mu.
+func (c *Conn) canSendNow(f *outFrame) bool {
+	if c.conn == nil || c.inflight != nil {
+		return false
+	}
+	if len(c.queue) > 0 && (!f.priority || c.queue[0].priority) {
+		return false
+	}
+	return c.limit.wait(f.priority, time.Now()) == 0
+}
+
+// claim takes f off to the wire: it counts against the window and is in
+// flight until the pong to the ping written behind it arrives. It is
+// claimed before it is written so a quick rejection finds it. Must hold
+// c.mu.
+func (c *Conn) claim(f *outFrame) {
+	now := time.Now()
+	c.limit.record(now)
+	f.fence = strconv.FormatInt(now.UnixNano(), 10)
+	c.inflight = f
+}
+
+// write puts f on conn, followed by the ping that fences it. A failed
+// write leaves the connection unusable, so it is closed for readLoop to
+// redial. Must hold c.wmu but not c.mu: a stalled socket blocks the write
+// until its deadline, and reading, pinging and queueing carry on meanwhile.
+func (c *Conn) write(conn *websocket.Conn, f *outFrame, deadline time.Time) error {
+	conn.SetWriteDeadline(deadline)
+	if err := conn.WriteMessage(websocket.TextMessage, f.data); err != nil {
+		conn.Close()
+		return err
+	}
+	if err := conn.WriteControl(websocket.PingMessage, []byte(f.fence), deadline); err != nil {
+		// f is out, so it is not failed; it is lost with the connection
+		c.log.Warn("ping failed", "err", err)
+		conn.Close()
+	}
+	return nil
+}
+
+// unclaim forgets a frame whose write failed. Must hold c.mu.
+func (c *Conn) unclaim(f *outFrame) {
+	if c.inflight == f {
+		c.inflight = nil
+	}
+}
+
+// settle takes the frame in flight off the wire once the pong to its
+// fence arrives. The server handles frames in order and answers one over
+// its limit at once, so by then any rejection of it has been read. Called
+// from the pong handler.
+func (c *Conn) settle(payload string) {
+	c.mu.Lock()
+	settled := c.inflight != nil && c.inflight.fence == payload
+	if settled {
+		c.inflight = nil
+	}
+	c.mu.Unlock()
+	if settled {
+		c.wakeWriter()
+	}
+}
+
+// unsend puts back a queued frame whose write failed, at the head of its
+// class where it was taken from, to go out on the next connection. If its
+// Send has given up waiting, or the Conn is shutting down, it is failed
+// instead, as is a user message. Must hold c.mu.
+func (c *Conn) unsend(f *outFrame, err error) {
+	c.unclaim(f)
+	if c.ctx.Err() != nil {
+		err = ErrClosed
+	} else if f.kind == "user_message" {
+		err = ErrNotSent
+	}
+	if f.abandoned || err == ErrClosed || err == ErrNotSent {
+		f.finish(err)
+		return
+	}
+	i := 0
+	if !f.priority {
+		for i < len(c.queue) && c.queue[i].priority {
+			i++
+		}
+	}
+	c.queue = append(c.queue, nil)
+	copy(c.queue[i+1:], c.queue[i:])
+	c.queue[i] = f
+}
+
+func (c *Conn) wakeWriter() {
+	select {
+	case c.wake <- struct{}{}:
+	default:
+	}
+}
+
+// writeLoop drains the queue as the window frees up, one frame in flight
+// at a time, and reports the queue depth on Events whenever it changes.
+func (c *Conn) writeLoop() {
+	defer close(c.writeDone)
+	timer := time.NewTimer(time.Hour)
+	timer.Stop()
+	depth := 0
+	for {
+		var delay time.Duration
+		for {
+			c.wmu.Lock()
+			c.mu.Lock()
+			// settle wakes us once the frame in flight is through
+			if len(c.queue) == 0 || c.conn == nil || c.inflight != nil {
+				c.mu.Unlock()
+				c.wmu.Unlock()
+				break
+			}
+			f := c.queue[0]
+			if delay = c.limit.wait(f.priority, time.Now()); delay > 0 {
+				c.mu.Unlock()
+				c.wmu.Unlock()
+				break
+			}
+			conn := c.conn
+			c.queue = c.queue[1:]
+			c.claim(f)
+			c.mu.Unlock()
+
+			err := c.write(conn, f, time.Now().Add(writeTimeout))
+			c.wmu.Unlock()
+			c.mu.Lock()
+			if err != nil {
+				c.unsend(f, err)
+			} else {
+				f.finish(nil)
+			}
+			c.mu.Unlock()
+			if err != nil {
+				// readLoop notices too, redials and wakes us
+				c.log.Warn("send failed", "err", err)
+				break
+			}
+			c.log.Debug("sent queued frame", "type", f.kind, "retry", f.retries)
+			c.logFrame("out", f.data)
+			c.record(true, f.data)
+		}
+
+		c.mu.Lock()
+		n := len(c.queue)
+		c.mu.Unlock()
+		if n != depth {
+			depth = n
+			if !c.emit(QueueChanged{Depth: n}) {
+				return
+			}
+		}
+		if delay > 0 {
+			timer.Reset(delay)
+		}
+		select {
+		case <-c.wake:
+		case <-timer.C:
+		case <-c.ctx.Done():
+			return
+		}
+	}
+}
+
+// track takes a rate-limit rejection off Events when it belongs to the
+// frame in flight, which is then queued again ahead of the rest. Only one
+// frame is ever in flight, so a rejection has exactly one owner. A user
+// message turned away maxRetries times is reported as Rejected instead.
+// It reports whether the frame was consumed.
+func (c *Conn) track(data []byte) bool {
+	var m struct {
+		Type      string `json:"type"`
+		SessionID string `json:"sessionId"`
+		Error     string `json:"error"`
+	}
+	if json.Unmarshal(data, &m) != nil || m.Type != "result" || m.SessionID != "" ||
+		!strings.HasPrefix(m.Error, rateLimitError) {
+		return false
+	}
+
+	c.mu.Lock()
+	c.limit.saturate(time.Now())
+	f := c.inflight
+	c.inflight = nil
+	if f == nil {
+		c.mu.Unlock()
+		c.log.Warn("rate limited by server", "frame", "unknown")
+		return false
+	}
+	if f.retries < maxRetries {
+		f.retries++
+		c.enqueue(f, true)
+		c.mu.Unlock()
+		c.log.Info("rate limited by server; retrying", "type", f.kind, "retry", f.retries)
+		c.wakeWriter()
+		return true
+	}
+	c.mu.Unlock()
+	c.log.Warn("rate limited by server; giving up", "type", f.kind, "retries", f.retries)
+	c.wakeWriter()
+	if f.kind != "user_message" {
+		return false
+	}
+	c.emit(rejected(f, false))
+	return true
+}
+
+// rejected reports user message f, given up on, to its owner.
+func rejected(f *outFrame, lost bool) Rejected {
+	var m UserMessage
+	json.Unmarshal(f.data, &m)
+	return Rejected{Message: m, Lost: lost}
+}
@@ -0,0 +1,177 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func testConn() *Conn {
+	c := &Conn{
+		log:    slog.New(slog.DiscardHandler),
+		seqs:   make(map[string]*seqState),
+		events: make(chan Event, 10),
+		wake:   make(chan struct{}, 1),
+	}
+	c.ctx, c.cancel = context.WithCancel(context.Background())
+	return c
+}
+
+func frame(t *testing.T, m ClientMessage) *outFrame {
+	t.Helper()
+	data, err := json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return newOutFrame(data)
+}
+
+func TestWindowWait(t *testing.T) {
+	now := time.Now()
+	var w window
+	for i := 0; i < rateLimit-rateReserve; i++ {
+		if d := w.wait(false, now); d != 0 {
+			t.Fatalf("frame %d waits %s in a window with room", i+1, d)
+		}
+		w.record(now.Add(time.Duration(i) * time.Second))
+	}
+	// The oldest send leaves the window a period plus the margin after it
+	want := ratePeriod + rateMargin
+	if d := w.wait(false, now); d != want {
+		t.Errorf("ordinary frame waits %s once the window is full, want %s", d, want)
+	}
+	if d := w.wait(true, now); d != 0 {
+		t.Errorf("priority frame waits %s with the reserve free", d)
+	}
+	if d := w.wait(false, now.Add(want)); d != 0 {
+		t.Errorf("ordinary frame waits %s once the oldest send expired", d)
+	}
+	w.record(now.Add(want))
+	if d := w.wait(false, now.Add(want)); d != time.Second {
+		t.Errorf("ordinary frame waits %s with the freed slot taken, want 1s", d)
+	}
+
+	w.reset()
+	w.saturate(now)
+	if len(w.sent) != rateLimit {
+		t.Fatalf("saturated window holds %d sends, want %d", len(w.sent), rateLimit)
+	}
+	if d := w.wait(true, now); d != want {
+		t.Errorf("priority frame waits %s after a rejection, want %s", d, want)
+	}
+	if d := w.wait(false, now.Add(want)); d != 0 {
+		t.Errorf("frame waits %s a period after the rejection", d)
+	}
+}
+
+func TestEnqueueOrder(t *testing.T) {
+	c := testConn()
+	a := frame(t, NewUserMessage("s", "a"))
+	b := frame(t, NewUserMessage("s", "b"))
+	p := frame(t, NewInterrupt("s"))
+	r := frame(t, NewUserMessage("s", "retried"))
+	r.retries = 1
+	rp := frame(t, NewPermissionResponse("s", "req", true))
+	rp.retries = 1
+
+	c.enqueue(a, false)
+	c.enqueue(b, false)
+	c.enqueue(p, false)
+	c.enqueue(r, true)
+	c.enqueue(rp, true)
+
+	want := []*outFrame{rp, p, r, a, b}
+	if len(c.queue) != len(want) {
+		t.Fatalf("queue holds %d frames, want %d", len(c.queue), len(want))
+	}
+	for i, f := range c.queue {
+		if f != want[i] {
+			t.Errorf("queue[%d] = %s %q, want %s %q", i, f.kind, f.data, want[i].kind, want[i].data)
+		}
+	}
+}
+
+const rejection = `{"type":"result","sessionId":"","success":false,"error":"Rate limit exceeded","seq":0}`
+
+func TestTrackRetries(t *testing.T) {
+	c := testConn()
+	msg := frame(t, UserMessage{Type: "user_message", SessionID: "s", Text: "hi", Seq: 4})
+
+	for retry := 1; retry <= maxRetries; retry++ {
+		c.queue = nil
+		c.claim(msg)
+		if !c.track([]byte(rejection)) {
+			t.Fatalf("rejection %d reached Events", retry)
+		}
+		if c.inflight != nil {
+			t.Fatal("the rejected frame is still in flight")
+		}
+		if len(c.queue) != 1 || c.queue[0] != msg || msg.retries != retry {
+			t.Fatalf("after rejection %d: queue = %v, retries %d; want the message queued again", retry, c.queue, msg.retries)
+		}
+		if d := c.limit.wait(true, time.Now()); d == 0 {
+			t.Error("the window has room right after a rejection")
+		}
+	}
+
+	c.queue = nil
+	c.claim(msg)
+	if !c.track([]byte(rejection)) {
+		t.Error("the last rejection of a user message reached Events")
+	}
+	if len(c.queue) != 0 {
+		t.Fatalf("queued again past maxRetries: %v", c.queue)
+	}
+	select {
+	case ev := <-c.events:
+		r, ok := ev.(Rejected)
+		if !ok || r.Message.Text != "hi" || r.Message.Seq != 4 || r.Lost {
+			t.Errorf("got %#v, want the message Rejected as sent", ev)
+		}
+	default:
+		t.Error("no Rejected once retries ran out")
+	}
+}
+
+func TestTrackGivesUpOnOtherFrames(t *testing.T) {
+	c := testConn()
+	perm := frame(t, NewPermissionResponse("s", "req", true))
+	perm.retries = maxRetries
+	c.claim(perm)
+	if c.track([]byte(rejection)) {
+		t.Error("the last rejection of a permission answer was consumed; it should show as an error")
+	}
+	if len(c.queue) != 0 || len(c.events) != 0 {
+		t.Errorf("queue = %v, %d events; want neither", c.queue, len(c.events))
+	}
+}
+
+func TestTrackUnknownFrame(t *testing.T) {
+	c := testConn()
+	if c.track([]byte(rejection)) {
+		t.Error("a rejection with nothing in flight was consumed")
+	}
+	if c.track([]byte(`{"type":"result","sessionId":"s","success":false,"error":"Rate limit exceeded"}`)) {
+		t.Error("a session's result was taken for a rejection")
+	}
+}
+
+func TestSettle(t *testing.T) {
+	c := testConn()
+	f := frame(t, NewUserMessage("s", "hi"))
+	c.claim(f)
+
+	c.settle("1")
+	if c.inflight != f {
+		t.Fatal("a pong to another ping settled the frame")
+	}
+	c.settle(f.fence)
+	if c.inflight != nil {
+		t.Fatal("the pong to its fence did not settle the frame")
+	}
+	if c.track([]byte(rejection)) {
+		t.Error("a rejection after the fence was pinned on the settled frame")
+	}
+}
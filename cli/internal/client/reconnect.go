@@ -0,0 +1,68 @@
+package client
+
+import (
+	"math/rand/v2"
+	"time"
+)
+
+const (
+	defaultMaxAttempts = 10
+	defaultMaxDelay    = 30 * time.Second
+	firstDelay         = time.Second
+)
+
+// ReconnectPolicy controls how a Conn redials after the connection drops.
+type ReconnectPolicy struct {
+	Disabled    bool // end the Conn on the first drop
+	MaxAttempts int
+	MaxDelay    time.Duration
+}
+
+// delay returns the wait before the given attempt, counted from 1. It
+// doubles from a second up to MaxDelay, with the upper half jittered so
+// that clients dropped together do not all redial at once.
+func (p ReconnectPolicy) delay(attempt int) time.Duration {
+	d := p.MaxDelay
+	if attempt <= 30 {
+		if exp := firstDelay << (attempt - 1); exp < d {
+			d = exp
+		}
+	}
+	return d/2 + rand.N(d/2+1)
+}
+
+// reconnect redials until it succeeds, the policy gives up or the Conn is
+// closed, which cancels both the wait and a dial in progress. It returns
+// nil once reconnected, or else the last error.
+func (c *Conn) reconnect(cause error) error {
+	p := c.redial
+	if p.Disabled {
+		return cause
+	}
+	for attempt := 1; attempt <= p.MaxAttempts; attempt++ {
+		if !c.emit(Reconnecting{Attempt: attempt, MaxAttempts: p.MaxAttempts, Err: cause}) {
+			return ErrClosed
+		}
+		delay := p.delay(attempt)
+		c.log.Info("reconnecting", "attempt", attempt, "delay", delay)
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-c.ctx.Done():
+			timer.Stop()
+			return ErrClosed
+		}
+		err := c.connect(c.ctx)
+		if err == nil {
+			if !c.emit(Reconnected{}) {
+				return ErrClosed
+			}
+			return nil
+		}
+		if c.ctx.Err() != nil {
+			return ErrClosed
+		}
+		cause = err
+	}
+	return cause
+}
@@ -1,10 +1,13 @@
 package client
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
+	"net/url"
 	"strings"
 	"time"
 )
@@ -13,6 +16,10 @@ type HealthResponse struct {
 	Status         string `json:"status"`
 	Timestamp      string `json:"timestamp"`
 	ActiveSessions int    `json:"activeSessions"`
+
+	// PlainText is set when the body was not the backend's JSON, as when a
+	// proxy answers /health itself. ActiveSessions is unknown then.
+	PlainText bool `json:"-"`
 }
 
 type Session struct {
@@ -40,20 +47,58 @@ type Project struct {
 	Name string `json:"name"`
 }
 
+// StatusError is returned when the server answers with an unexpected
+// HTTP status.
+type StatusError struct {
+	Op   string // what was attempted, e.g. "list sessions"
+	Code int
+	Body string
+}
+
+func (e *StatusError) Error() string {
+	if e.Unauthorized() {
+		return fmt.Sprintf("%s failed (%d): token rejected by server", e.Op, e.Code)
+	}
+	return fmt.Sprintf("%s failed (%d): %s", e.Op, e.Code, e.Body)
+}
+
+// Unauthorized reports whether the server rejected the auth token.
+func (e *StatusError) Unauthorized() bool {
+	return e.Code == http.StatusUnauthorized || e.Code == http.StatusForbidden
+}
+
+func statusError(op string, resp *http.Response) error {
+	b, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	return &StatusError{Op: op, Code: resp.StatusCode, Body: strings.TrimSpace(string(b))}
+}
+
 type RESTClient struct {
 	baseURL string
 	token   string
 	http    *http.Client
+	log     *slog.Logger
 }
 
-func NewRESTClient(baseURL, token string) *RESTClient {
+func NewRESTClient(baseURL, token string, opts ...Option) *RESTClient {
+	o := buildOptions(opts)
 	return &RESTClient{
 		baseURL: strings.TrimRight(baseURL, "/"),
 		token:   token,
 		http:    &http.Client{Timeout: 30 * time.Second},
+		log:     o.logger.With("component", "rest"),
 	}
 }
 
+// jsonBody encodes v as a request body. Go's %q quoting is not JSON, so
+// bodies are never built with Sprintf.
+func jsonBody(v any) (io.Reader, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(data), nil
+}
+
 func (c *RESTClient) do(method, path string, body io.Reader) (*http.Response, error) {
 	req, err := http.NewRequest(method, c.baseURL+path, body)
 	if err != nil {
@@ -65,7 +110,14 @@ func (c *RESTClient) do(method, path string, body io.Reader) (*http.Response, er
 	if body != nil {
 		req.Header.Set("Content-Type", "application/json")
 	}
-	return c.http.Do(req)
+	start := time.Now()
+	resp, err := c.http.Do(req)
+	if err != nil {
+		c.log.Warn("request failed", "method", method, "path", path, "err", err)
+		return nil, err
+	}
+	c.log.Debug("request", "method", method, "path", path, "status", resp.StatusCode, "duration", time.Since(start))
+	return resp, nil
 }
 
 func (c *RESTClient) Health() (*HealthResponse, error) {
@@ -74,6 +126,9 @@ func (c *RESTClient) Health() (*HealthResponse, error) {
 		return nil, err
 	}
 	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return nil, statusError("health", resp)
+	}
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
 		return nil, err
@@ -82,21 +137,26 @@ func (c *RESTClient) Health() (*HealthResponse, error) {
 	if err := json.Unmarshal(body, &h); err != nil {
 		// Plain text response (e.g. Traefik health intercept)
 		h.Status = strings.TrimSpace(string(body))
+		h.PlainText = true
 		return &h, nil
 	}
 	return &h, nil
 }
 
 func (c *RESTClient) CreateSession(projectPath string) (*Session, error) {
-	body := strings.NewReader(fmt.Sprintf(`{"projectPath":%q}`, projectPath))
+	body, err := jsonBody(struct {
+		ProjectPath string `json:"projectPath"`
+	}{projectPath})
+	if err != nil {
+		return nil, err
+	}
 	resp, err := c.do("POST", "/api/sessions", body)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode != 201 {
-		b, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("create session failed (%d): %s", resp.StatusCode, b)
+		return nil, statusError("create session", resp)
 	}
 	var s Session
 	return &s, json.NewDecoder(resp.Body).Decode(&s)
@@ -108,12 +168,15 @@ func (c *RESTClient) ListSessions() ([]Session, error) {
 		return nil, err
 	}
 	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return nil, statusError("list sessions", resp)
+	}
 	var sessions []Session
 	return sessions, json.NewDecoder(resp.Body).Decode(&sessions)
 }
 
 func (c *RESTClient) GetSession(id string, since int) (*SessionDetail, error) {
-	path := fmt.Sprintf("/api/sessions/%s", id)
+	path := "/api/sessions/" + url.PathEscape(id)
 	if since > 0 {
 		path += fmt.Sprintf("?since=%d", since)
 	}
@@ -125,16 +188,62 @@ func (c *RESTClient) GetSession(id string, since int) (*SessionDetail, error) {
 	if resp.StatusCode == 404 {
 		return nil, fmt.Errorf("session %s not found", id)
 	}
+	if resp.StatusCode != 200 {
+		return nil, statusError("get session", resp)
+	}
 	var sd SessionDetail
 	return &sd, json.NewDecoder(resp.Body).Decode(&sd)
 }
 
+func (c *RESTClient) DeleteSession(id string) error {
+	resp, err := c.do("DELETE", "/api/sessions/"+url.PathEscape(id), nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == 404 {
+		return fmt.Errorf("session %s not found", id)
+	}
+	if resp.StatusCode != 204 {
+		return statusError("delete session", resp)
+	}
+	return nil
+}
+
 func (c *RESTClient) ListProjects() ([]Project, error) {
 	resp, err := c.do("GET", "/api/projects", nil)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return nil, statusError("list projects", resp)
+	}
 	var projects []Project
 	return projects, json.NewDecoder(resp.Body).Decode(&projects)
 }
+
+// CreateProject creates a git repository in the server user's home
+// directory. The server strips characters other than letters, digits, ".",
+// "_" and "-" from name.
+func (c *RESTClient) CreateProject(name string) (*Project, error) {
+	body, err := jsonBody(struct {
+		Name string `json:"name"`
+	}{name})
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.do("POST", "/api/projects", body)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == 409 {
+		return nil, fmt.Errorf("project %s already exists", name)
+	}
+	if resp.StatusCode != 201 {
+		return nil, statusError("create project", resp)
+	}
+	var p Project
+	return &p, json.NewDecoder(resp.Body).Decode(&p)
+}
@@ -0,0 +1,42 @@
+package client
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSessionPathEscaped(t *testing.T) {
+	var paths []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.Method+" "+r.URL.EscapedPath())
+		if r.Method == "DELETE" {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		json.NewEncoder(w).Encode(SessionDetail{})
+	}))
+	defer srv.Close()
+
+	c := NewRESTClient(srv.URL, "")
+	id := "../projects?x=1#y"
+	if _, err := c.GetSession(id, 0); err != nil {
+		t.Fatalf("GetSession: %v", err)
+	}
+	if err := c.DeleteSession(id); err != nil {
+		t.Fatalf("DeleteSession: %v", err)
+	}
+	want := []string{
+		"GET /api/sessions/..%2Fprojects%3Fx=1%23y",
+		"DELETE /api/sessions/..%2Fprojects%3Fx=1%23y",
+	}
+	if len(paths) != len(want) {
+		t.Fatalf("server got %q, want %q", paths, want)
+	}
+	for i := range want {
+		if paths[i] != want[i] {
+			t.Errorf("request %d = %q, want %q", i, paths[i], want[i])
+		}
+	}
+}
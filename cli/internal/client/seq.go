@@ -0,0 +1,113 @@
+package client
+
+// The server numbers the frames of a session from a single counter: the
+// user message takes one number, unseen by the client, and each chunk,
+// tool event and final assistant message of the answer the next. A
+// successful result repeats the number of the assistant message; errors
+// carry 0. Within a turn the numbers the client sees are consecutive.
+
+// SeqGap reports frames of a session's turn that never arrived, numbered
+// From to To. Server history keeps only the final reply of each turn, so
+// lost text is made up for by it, but lost tool events are not, and
+// neither are permission requests, which carry no number at all.
+type SeqGap struct {
+	SessionID string
+	From      int
+	To        int
+	Running   bool // the turn's reply has not arrived yet
+}
+
+func (SeqGap) event() {}
+
+// seqState is what the client knows about one session's numbering.
+type seqState struct {
+	last   int  // highest seq delivered
+	inTurn bool // a numbered frame of the running turn was delivered
+	sent   int  // number of the last user message sent
+}
+
+// sequence checks a server frame against its session's numbering. It
+// reports whether the frame is new, and any gap before it. Frames at or
+// below the last number seen are duplicates, or stale after a reconnect,
+// and are dropped. Must hold c.mu.
+func (c *Conn) sequence(ev Event) (ok bool, gap *SeqGap) {
+	sid := FrameSessionID(ev)
+	if sid == "" {
+		return true, nil
+	}
+	s := c.seqs[sid]
+	if s == nil {
+		s = &seqState{}
+		c.seqs[sid] = s
+	}
+
+	var seq int
+	switch m := ev.(type) {
+	case *AssistantChunk:
+		seq = m.Seq
+	case *AssistantMessageMsg:
+		seq = m.Seq
+	case *ToolEvent:
+		seq = m.Seq
+	case *SessionState:
+		// Sent as a turn starts and ends, and on switch_session; what
+		// follows may be numbered past a user message.
+		s.inTurn = false
+		return true, nil
+	case *ResultMessage:
+		if m.Seq == 0 {
+			s.inTurn = false
+			return true, nil
+		}
+		if m.Seq < s.last || (m.Seq == s.last && !s.inTurn) {
+			return false, nil
+		}
+		if s.inTurn && m.Seq > s.last {
+			// The assistant message sharing its number was lost too
+			gap = &SeqGap{SessionID: sid, From: s.last + 1, To: m.Seq}
+		}
+		s.last = m.Seq
+		s.inTurn = false
+		return true, gap
+	default:
+		return true, nil
+	}
+
+	if seq <= s.last {
+		return false, nil
+	}
+	if s.inTurn && seq > s.last+1 {
+		_, final := ev.(*AssistantMessageMsg)
+		gap = &SeqGap{SessionID: sid, From: s.last + 1, To: seq - 1, Running: !final}
+	}
+	s.last = seq
+	s.inTurn = true
+	return true, gap
+}
+
+// number gives a user message the session's next client seq, unless it
+// has one. Numbers count from 1 for each Conn, and a message sent again
+// with its Seq set keeps it, so transcripts can tell the repeat apart.
+// Must hold c.mu.
+func (c *Conn) number(m ClientMessage) ClientMessage {
+	um, ok := m.(UserMessage)
+	if !ok || um.Seq != 0 {
+		return m
+	}
+	s := c.seqs[um.SessionID]
+	if s == nil {
+		s = &seqState{}
+		c.seqs[um.SessionID] = s
+	}
+	s.sent++
+	um.Seq = s.sent
+	return um
+}
+
+// Number gives m its session's next client seq, as Send would, for a
+// caller that may have to send m again and wants it to keep its number.
+func (c *Conn) Number(m UserMessage) UserMessage {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.number(m).(UserMessage)
+}
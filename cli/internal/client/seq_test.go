@@ -0,0 +1,157 @@
+package client
+
+import "testing"
+
+func chunk(sid string, seq int) Event {
+	return &AssistantChunk{Type: "assistant_chunk", SessionID: sid, Seq: seq}
+}
+
+func tool(sid string, seq int) Event {
+	return &ToolEvent{Type: "tool_event", SessionID: sid, Seq: seq}
+}
+
+func reply(sid string, seq int) Event {
+	return &AssistantMessageMsg{Type: "assistant_message", SessionID: sid, Seq: seq}
+}
+
+func result(sid string, seq int, ok bool) Event {
+	return &ResultMessage{Type: "result", SessionID: sid, Success: ok, Seq: seq}
+}
+
+func state(sid string) Event {
+	return &SessionState{Type: "session_state", SessionID: sid, Status: "busy"}
+}
+
+func TestSequence(t *testing.T) {
+	// want is one letter per frame: k kept, d dropped, g kept after a gap
+	tests := []struct {
+		name   string
+		frames []Event
+		want   string
+		gap    SeqGap // the last gap reported
+	}{
+		{
+			name:   "a whole turn",
+			frames: []Event{state("s"), chunk("s", 2), tool("s", 3), chunk("s", 4), reply("s", 5), result("s", 5, true), state("s")},
+			want:   "kkkkkkk",
+		},
+		{
+			name:   "gap while the answer runs",
+			frames: []Event{chunk("s", 2), chunk("s", 5)},
+			want:   "kg",
+			gap:    SeqGap{SessionID: "s", From: 3, To: 4, Running: true},
+		},
+		{
+			name:   "gap before the reply",
+			frames: []Event{chunk("s", 2), tool("s", 3), reply("s", 6)},
+			want:   "kkg",
+			gap:    SeqGap{SessionID: "s", From: 4, To: 5},
+		},
+		{
+			name:   "reply lost with the frames before it",
+			frames: []Event{chunk("s", 2), result("s", 5, true)},
+			want:   "kg",
+			gap:    SeqGap{SessionID: "s", From: 3, To: 5},
+		},
+		{
+			name:   "repeated chunk",
+			frames: []Event{chunk("s", 2), chunk("s", 2), chunk("s", 3)},
+			want:   "kdk",
+		},
+		{
+			name:   "stale frames after a reconnect",
+			frames: []Event{chunk("s", 2), chunk("s", 3), tool("s", 4), chunk("s", 3), tool("s", 4), chunk("s", 5)},
+			want:   "kkkddk",
+		},
+		{
+			name:   "result repeats the reply's seq",
+			frames: []Event{chunk("s", 2), reply("s", 3), result("s", 3, true), result("s", 3, true)},
+			want:   "kkkd",
+		},
+		{
+			name:   "result of a turn whose frames were all seen before",
+			frames: []Event{reply("s", 3), result("s", 3, true), state("s"), result("s", 3, true)},
+			want:   "kkkd",
+		},
+		{
+			name:   "errors carry seq 0",
+			frames: []Event{chunk("s", 2), result("s", 0, false), result("s", 0, false), chunk("s", 4)},
+			want:   "kkkk",
+		},
+		{
+			name:   "the next turn skips the user message",
+			frames: []Event{chunk("s", 2), reply("s", 3), result("s", 3, true), chunk("s", 5)},
+			want:   "kkkk",
+		},
+		{
+			name:   "first frame seen mid-turn",
+			frames: []Event{chunk("s", 7), chunk("s", 8)},
+			want:   "kk",
+		},
+		{
+			name:   "sessions are numbered apart",
+			frames: []Event{chunk("a", 2), chunk("b", 9), chunk("a", 3), chunk("b", 10)},
+			want:   "kkkk",
+		},
+		{
+			name:   "frames without a session",
+			frames: []Event{result("", 0, false), result("", 0, false)},
+			want:   "kk",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Conn{seqs: make(map[string]*seqState)}
+			var got []byte
+			var last SeqGap
+			for _, ev := range tt.frames {
+				ok, gap := c.sequence(ev)
+				switch {
+				case gap != nil && !ok:
+					t.Fatalf("frame %T reported a gap but was dropped", ev)
+				case gap != nil:
+					got = append(got, 'g')
+					last = *gap
+				case ok:
+					got = append(got, 'k')
+				default:
+					got = append(got, 'd')
+				}
+			}
+			if string(got) != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+			if last != tt.gap {
+				t.Errorf("gap = %+v, want %+v", last, tt.gap)
+			}
+		})
+	}
+}
+
+func TestNumber(t *testing.T) {
+	c := &Conn{seqs: make(map[string]*seqState)}
+	seqOf := func(m ClientMessage) int {
+		return c.number(m).(UserMessage).Seq
+	}
+	for i, sid := range []string{"a", "a", "b", "a", "b"} {
+		want := []int{1, 2, 1, 3, 2}[i]
+		if got := seqOf(NewUserMessage(sid, "hi")); got != want {
+			t.Errorf("message %d to %s numbered %d, want %d", i+1, sid, got, want)
+		}
+	}
+	// Sent again, a message keeps its number and takes no new one
+	if got := seqOf(UserMessage{Type: "user_message", SessionID: "a", Text: "hi", Seq: 2}); got != 2 {
+		t.Errorf("resent message numbered %d, want 2", got)
+	}
+	if got := seqOf(NewUserMessage("a", "hi")); got != 4 {
+		t.Errorf("message after a resend numbered %d, want 4", got)
+	}
+	if m := c.number(NewInterrupt("a")); m != NewInterrupt("a") {
+		t.Errorf("interrupt changed to %+v", m)
+	}
+
+	c = &Conn{seqs: make(map[string]*seqState)}
+	if got := seqOf(NewUserMessage("a", "hi")); got != 1 {
+		t.Errorf("a new Conn numbered its first message %d, want 1", got)
+	}
+}
@@ -4,18 +4,56 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
 
 type Server struct {
-	Name  string `yaml:"name"`
-	URL   string `yaml:"url"`
-	Token string `yaml:"token"`
+	Name         string           `yaml:"name"`
+	URL          string           `yaml:"url"`
+	Token        string           `yaml:"token"`
+	Permissions  []PermissionRule `yaml:"permissions,omitempty"`
+	Projects     []Project        `yaml:"projects,omitempty"`
+	PathMappings []PathMapping    `yaml:"path_mappings,omitempty"`
+
+	// Heartbeat and redialing on the WebSocket; zero keeps the client's
+	// defaults
+	PingInterval time.Duration `yaml:"ping_interval,omitempty"`
+	PongTimeout  time.Duration `yaml:"pong_timeout,omitempty"`
+	Reconnect    *bool         `yaml:"reconnect,omitempty"` // nil means true
+	MaxAttempts  int           `yaml:"max_attempts,omitempty"`
+	MaxDelay     time.Duration `yaml:"max_delay,omitempty"`
+}
+
+// PathMapping pairs a local directory with the path the server sees it at,
+// for servers that mount the same repositories elsewhere.
+type PathMapping struct {
+	Local  string `yaml:"local"`
+	Remote string `yaml:"remote"`
+}
+
+// Project holds settings for one remote project path on a server.
+type Project struct {
+	Path        string           `yaml:"path"`
+	Permissions []PermissionRule `yaml:"permissions,omitempty"`
+}
+
+// PermissionRule answers permission requests without prompting. Tool and
+// the Input values are globs ("*" matches anything, including "/"); an
+// Input value prefixed with "re:" is a regular expression instead.
+type PermissionRule struct {
+	Action string            `yaml:"action"` // allow, deny or ask
+	Tool   string            `yaml:"tool"`
+	Input  map[string]string `yaml:"input,omitempty"`
 }
 
 type Config struct {
-	Servers []Server `yaml:"servers"`
+	DefaultServer   string           `yaml:"default_server,omitempty"`   // used when --server is not given
+	TrustedProjects []string         `yaml:"trusted_projects,omitempty"` // directories whose project file may allow tools
+	Permissions     []PermissionRule `yaml:"permissions,omitempty"`
+	Servers         []Server         `yaml:"servers"`
 }
 
 func DefaultPath() string {
@@ -63,6 +101,69 @@ func (c *Config) FindServer(name string) (*Server, error) {
 	return nil, fmt.Errorf("server %q not found in config", name)
 }
 
+// FindProject returns the project settings that apply to path: the entry
+// with the longest path that equals path or is one of its parents.
+func (s *Server) FindProject(path string) *Project {
+	var best *Project
+	for i := range s.Projects {
+		p := &s.Projects[i]
+		root := strings.TrimRight(p.Path, "/")
+		if !hasPathPrefix(path, root) {
+			continue
+		}
+		if best == nil || len(root) > len(strings.TrimRight(best.Path, "/")) {
+			best = p
+		}
+	}
+	return best
+}
+
+// ToRemote translates a local path to the server's view using the mapping
+// with the longest matching local prefix. Unmapped paths are returned as is.
+func (s *Server) ToRemote(path string) string {
+	if mapped, ok := s.mapPath(filepath.ToSlash(path), true); ok {
+		return mapped
+	}
+	return path
+}
+
+// ToLocal is the inverse of ToRemote.
+func (s *Server) ToLocal(path string) string {
+	if mapped, ok := s.mapPath(path, false); ok {
+		return filepath.FromSlash(mapped)
+	}
+	return path
+}
+
+func (s *Server) mapPath(path string, toRemote bool) (string, bool) {
+	best, bestLen := "", -1
+	for _, m := range s.PathMappings {
+		from, to := filepath.ToSlash(m.Local), m.Remote
+		if !toRemote {
+			from, to = to, from
+		}
+		from = strings.TrimRight(from, "/")
+		if !hasPathPrefix(path, from) || len(from) <= bestLen {
+			continue
+		}
+		rest := path[len(from):]
+		if rest == "/" {
+			// path is from itself, as when from is the root
+			rest = ""
+		}
+		best, bestLen = strings.TrimRight(to, "/")+rest, len(from)
+	}
+	if best == "" && bestLen >= 0 {
+		best = "/"
+	}
+	return best, bestLen >= 0
+}
+
+// hasPathPrefix reports whether path is root or lies below it.
+func hasPathPrefix(path, root string) bool {
+	return path == root || strings.HasPrefix(path, root+"/")
+}
+
 func defaultConfig() *Config {
 	return &Config{
 		Servers: []Server{
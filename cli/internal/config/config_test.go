@@ -0,0 +1,103 @@
+package config
+
+import "testing"
+
+func TestPathMappings(t *testing.T) {
+	tests := []struct {
+		name     string
+		mappings []PathMapping
+		local    string
+		remote   string
+	}{
+		{
+			name:     "below a mapping",
+			mappings: []PathMapping{{Local: "/home/dev/src", Remote: "/workspace"}},
+			local:    "/home/dev/src/api",
+			remote:   "/workspace/api",
+		},
+		{
+			name:     "the mapped directory itself",
+			mappings: []PathMapping{{Local: "/home/dev/src", Remote: "/workspace"}},
+			local:    "/home/dev/src",
+			remote:   "/workspace",
+		},
+		{
+			name:     "trailing slashes are ignored",
+			mappings: []PathMapping{{Local: "/home/dev/src/", Remote: "/workspace/"}},
+			local:    "/home/dev/src/api",
+			remote:   "/workspace/api",
+		},
+		{
+			name: "longest prefix wins",
+			mappings: []PathMapping{
+				{Local: "/home/dev", Remote: "/home"},
+				{Local: "/home/dev/src", Remote: "/workspace"},
+			},
+			local:  "/home/dev/src/api",
+			remote: "/workspace/api",
+		},
+		{
+			name: "shorter prefix when the longer does not match",
+			mappings: []PathMapping{
+				{Local: "/home/dev/src", Remote: "/workspace"},
+				{Local: "/home/dev", Remote: "/home"},
+			},
+			local:  "/home/dev/notes",
+			remote: "/home/notes",
+		},
+		{
+			name:     "local root",
+			mappings: []PathMapping{{Local: "/", Remote: "/host"}},
+			local:    "/etc/hosts",
+			remote:   "/host/etc/hosts",
+		},
+		{
+			name:     "local root itself",
+			mappings: []PathMapping{{Local: "/", Remote: "/host"}},
+			local:    "/",
+			remote:   "/host",
+		},
+		{
+			name:     "remote root",
+			mappings: []PathMapping{{Local: "/srv/mirror", Remote: "/"}},
+			local:    "/srv/mirror/api",
+			remote:   "/api",
+		},
+		{
+			name:     "remote root itself",
+			mappings: []PathMapping{{Local: "/srv/mirror", Remote: "/"}},
+			local:    "/srv/mirror",
+			remote:   "/",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Server{PathMappings: tt.mappings}
+			if got := s.ToRemote(tt.local); got != tt.remote {
+				t.Errorf("ToRemote(%q) = %q, want %q", tt.local, got, tt.remote)
+			}
+			if got := s.ToLocal(tt.remote); got != tt.local {
+				t.Errorf("ToLocal(%q) = %q, want %q", tt.remote, got, tt.local)
+			}
+		})
+	}
+}
+
+func TestPathMappingsLeaveOthers(t *testing.T) {
+	s := &Server{PathMappings: []PathMapping{{Local: "/home/dev/src", Remote: "/workspace"}}}
+	for _, p := range []string{
+		"/home/dev/srcx",
+		"/home/dev/srcx/api",
+		"/home/dev",
+		"/opt/src",
+	} {
+		if got := s.ToRemote(p); got != p {
+			t.Errorf("ToRemote(%q) = %q, want it unchanged", p, got)
+		}
+	}
+	for _, p := range []string{"/workspacex/api", "/work", "/tmp"} {
+		if got := s.ToLocal(p); got != p {
+			t.Errorf("ToLocal(%q) = %q, want it unchanged", p, got)
+		}
+	}
+}
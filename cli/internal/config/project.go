@@ -0,0 +1,113 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ProjectFileName is the per-repository settings file, looked up from the
+// working directory upwards.
+const ProjectFileName = ".remote-ai-ide.yaml"
+
+// ProjectFile pins settings for the repository it sits in.
+type ProjectFile struct {
+	Server      string           `yaml:"server,omitempty"`  // server to use unless --server or the env var says otherwise
+	Project     string           `yaml:"project,omitempty"` // remote project path for new sessions
+	Permissions []PermissionRule `yaml:"permissions,omitempty"`
+
+	Path string `yaml:"-"` // where the file was found
+}
+
+// FindProjectFile looks for ProjectFileName in dir and its parents. Files in
+// skip, such as the user config that shares the name in the home directory,
+// are passed over. It returns nil when there is none.
+func FindProjectFile(dir string, skip ...string) (*ProjectFile, error) {
+	var skipped []os.FileInfo
+	for _, p := range skip {
+		if st, err := os.Stat(p); err == nil {
+			skipped = append(skipped, st)
+		}
+	}
+	for {
+		path := filepath.Join(dir, ProjectFileName)
+		if st, err := os.Stat(path); err == nil && !st.IsDir() && !sameAny(st, skipped) {
+			return loadProjectFile(path)
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil, nil
+		}
+		dir = parent
+	}
+}
+
+func sameAny(st os.FileInfo, others []os.FileInfo) bool {
+	for _, o := range others {
+		if os.SameFile(st, o) {
+			return true
+		}
+	}
+	return false
+}
+
+func loadProjectFile(path string) (*ProjectFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading project file: %w", err)
+	}
+	var f ProjectFile
+	if err := yaml.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	f.Path = path
+	return &f, nil
+}
+
+// Dir is the local directory the file applies to.
+func (f *ProjectFile) Dir() string {
+	return filepath.Dir(f.Path)
+}
+
+// AppliesTo reports whether the file's project settings are meant for srv;
+// a file that pins no server applies to all of them.
+func (f *ProjectFile) AppliesTo(srv *Server) bool {
+	return f.Server == "" || f.Server == srv.Name
+}
+
+// Covers reports whether a remote project path on srv belongs to the file:
+// the pinned project, or the file's directory as the server sees it.
+func (f *ProjectFile) Covers(srv *Server, project string) bool {
+	if !f.AppliesTo(srv) {
+		return false
+	}
+	root := f.Project
+	if root == "" {
+		root = srv.ToRemote(f.Dir())
+	}
+	return hasPathPrefix(project, filepath.ToSlash(filepath.Clean(root)))
+}
+
+// Trusts reports whether the project file's directory is listed in
+// trusted_projects. Untrusted files cannot allow anything on their own,
+// since they arrive with whatever repository was cloned.
+func (c *Config) Trusts(f *ProjectFile) bool {
+	dir := filepath.Clean(f.Dir())
+	for _, t := range c.TrustedProjects {
+		if abs, err := filepath.Abs(expandHome(t)); err == nil && filepath.Clean(abs) == dir {
+			return true
+		}
+	}
+	return false
+}
+
+func expandHome(path string) string {
+	if path == "~" || len(path) > 1 && path[:2] == "~/" {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, path[1:])
+		}
+	}
+	return path
+}
@@ -0,0 +1,249 @@
+package doctor
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/arvid/remote-ai-ide/cli/internal/client"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	slowHealth  = time.Second
+	certWarning = 14 * 24 * time.Hour
+)
+
+func checkDNS(ctx context.Context, d *run) (Result, bool) {
+	if net.ParseIP(d.host) != nil {
+		return Result{Status: Pass, Detail: d.host + " is an IP address"}, false
+	}
+	ctx, cancel := context.WithTimeout(ctx, Timeout)
+	defer cancel()
+	addrs, err := net.DefaultResolver.LookupHost(ctx, d.host)
+	if err != nil {
+		return Result{
+			Status: Fail,
+			Detail: err.Error(),
+			Hint:   "check the host name in the server URL, and your DNS or VPN",
+		}, true
+	}
+	return Result{Status: Pass, Detail: d.host + " → " + strings.Join(addrs, ", ")}, false
+}
+
+func checkTCP(ctx context.Context, d *run) (Result, bool) {
+	ctx, cancel := context.WithTimeout(ctx, Timeout)
+	defer cancel()
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(d.host, d.port))
+	if err != nil {
+		return Result{
+			Status: Fail,
+			Detail: err.Error(),
+			Hint:   "check that the server is running and port " + d.port + " is reachable (firewall, ingress, port-forward)",
+		}, true
+	}
+	defer conn.Close()
+	return Result{Status: Pass, Detail: "connected to " + conn.RemoteAddr().String()}, false
+}
+
+func checkTLS(ctx context.Context, d *run) (Result, bool) {
+	if d.url.Scheme != "https" {
+		if isLoopback(d.host) {
+			return Result{Status: Pass, Detail: "plain HTTP on this machine"}, false
+		}
+		return Result{
+			Status: Warn,
+			Detail: "plain HTTP: the token is sent unencrypted",
+			Hint:   "serve the backend over HTTPS, e.g. through the ingress",
+		}, false
+	}
+
+	dialer := &tls.Dialer{
+		NetDialer: &net.Dialer{Timeout: Timeout},
+		Config:    &tls.Config{ServerName: d.host, RootCAs: d.roots},
+	}
+	ctx, cancel := context.WithTimeout(ctx, Timeout)
+	defer cancel()
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(d.host, d.port))
+	if err != nil {
+		return Result{Status: Fail, Detail: err.Error(), Hint: tlsHint(err)}, true
+	}
+	defer conn.Close()
+
+	cert := conn.(*tls.Conn).ConnectionState().PeerCertificates[0]
+	left := time.Until(cert.NotAfter)
+	detail := fmt.Sprintf("valid until %s (%d days), issued by %s",
+		cert.NotAfter.Format("2006-01-02"), int(left.Hours()/24), cert.Issuer.CommonName)
+	if left < certWarning {
+		return Result{Status: Warn, Detail: detail, Hint: "renew the certificate soon"}, false
+	}
+	return Result{Status: Pass, Detail: detail}, false
+}
+
+func tlsHint(err error) string {
+	var invalid x509.CertificateInvalidError
+	var unknown x509.UnknownAuthorityError
+	var hostname x509.HostnameError
+	switch {
+	case errors.As(err, &invalid) && invalid.Reason == x509.Expired:
+		return "the certificate has expired or is not yet valid; renew it, and check this machine's clock"
+	case errors.As(err, &unknown):
+		return "the certificate is not signed by a trusted CA; install the CA or use a publicly trusted certificate"
+	case errors.As(err, &hostname):
+		return "the certificate does not cover this host name; use the name it was issued for"
+	}
+	return "check that the port serves TLS, or use http:// if it does not"
+}
+
+func isLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func checkHealth(ctx context.Context, d *run) (Result, bool) {
+	start := time.Now()
+	h, err := client.NewRESTClient(d.target.URL, "").Health()
+	latency := time.Since(start)
+	if err != nil {
+		return Result{
+			Status: Fail,
+			Detail: err.Error(),
+			Hint:   "the backend is not answering /health; check its logs and that the proxy routes to it",
+		}, true
+	}
+	if h.PlainText {
+		return Result{
+			Status: Warn,
+			Detail: fmt.Sprintf("plain-text answer %q: a proxy replied, not the backend", h.Status),
+			Hint:   "the checks below show whether the backend itself is reachable",
+		}, false
+	}
+	detail := fmt.Sprintf("%s, %d active sessions, %dms", h.Status, h.ActiveSessions, latency.Milliseconds())
+	if latency > slowHealth {
+		return Result{Status: Warn, Detail: detail, Hint: "the server is slow to answer; check its load and the network"}, false
+	}
+	return Result{Status: Pass, Detail: detail}, false
+}
+
+func checkAuth(ctx context.Context, d *run) (Result, bool) {
+	if d.target.TokenErr != nil {
+		return Result{
+			Status: Fail,
+			Detail: d.target.TokenErr.Error(),
+			Hint:   "fix the token with: servers edit " + d.target.Name,
+		}, true
+	}
+	if _, err := client.NewRESTClient(d.target.URL, d.target.Token).ListSessions(); err != nil {
+		var se *client.StatusError
+		if errors.As(err, &se) && se.Unauthorized() {
+			return Result{
+				Status: Fail,
+				Detail: err.Error(),
+				Hint:   "the token must be one of the server's AUTH_TOKENS; update it with: servers edit " + d.target.Name + " --token-stdin",
+			}, true
+		}
+		return Result{
+			Status: Fail,
+			Detail: err.Error(),
+			Hint:   "/api/* is not reaching the backend; check the proxy routes",
+		}, true
+	}
+	if _, err := client.NewRESTClient(d.target.URL, "").ListSessions(); err == nil {
+		d.open = true
+		return Result{
+			Status: Warn,
+			Detail: "token accepted, but so are requests without one",
+			Hint:   "set AUTH_TOKENS on the server so it requires a token",
+		}, false
+	}
+	return Result{Status: Pass, Detail: "token accepted on /api/sessions; requests without one are rejected"}, false
+}
+
+func checkWebSocket(ctx context.Context, d *run) (Result, bool) {
+	closed, err := dialWS(ctx, d.target.URL, d.target.Token)
+	if err != nil {
+		return Result{
+			Status: Fail,
+			Detail: err.Error(),
+			Hint:   "the upgrade on /ws failed; the proxy must pass the Upgrade and Connection headers",
+		}, true
+	}
+	if closed == client.CloseUnauthorized {
+		return Result{
+			Status: Fail,
+			Detail: "connection closed with 4001 Unauthorized",
+			Hint:   "the token is accepted on /api but not /ws; check for a proxy rewriting the query string",
+		}, true
+	}
+	if d.open {
+		return Result{Status: Pass, Detail: "upgrade accepted"}, false
+	}
+	closed, err = dialWS(ctx, d.target.URL, "remote-ai-ide-doctor-invalid")
+	if err == nil && closed != client.CloseUnauthorized {
+		return Result{
+			Status: Warn,
+			Detail: "upgrade accepted, but an invalid token was not closed with 4001",
+			Hint:   "set AUTH_TOKENS on the server so /ws requires a token",
+		}, false
+	}
+	return Result{Status: Pass, Detail: "upgrade accepted; invalid tokens are closed with 4001"}, false
+}
+
+// dialWS opens /ws and waits briefly for the server to close it, returning
+// the close code, or 0 if the connection stayed open.
+func dialWS(ctx context.Context, baseURL, token string) (int, error) {
+	wsURL, err := client.WSURL(baseURL, token)
+	if err != nil {
+		return 0, err
+	}
+	dialer := websocket.Dialer{HandshakeTimeout: Timeout, Proxy: http.ProxyFromEnvironment}
+	conn, resp, err := dialer.DialContext(ctx, wsURL, nil)
+	if err != nil {
+		if resp != nil {
+			return 0, fmt.Errorf("%w (HTTP %d)", err, resp.StatusCode)
+		}
+		return 0, err
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	_, _, err = conn.ReadMessage()
+	var ce *websocket.CloseError
+	if errors.As(err, &ce) {
+		return ce.Code, nil
+	}
+	return 0, nil
+}
+
+func checkCapacity(ctx context.Context, d *run) (Result, bool) {
+	rest := client.NewRESTClient(d.target.URL, d.target.Token)
+	s, err := rest.CreateSession("")
+	if err != nil {
+		var se *client.StatusError
+		if errors.As(err, &se) && se.Code == http.StatusServiceUnavailable {
+			return Result{
+				Status: Fail,
+				Detail: se.Body,
+				Hint:   "the server is at MAX_SESSIONS; free slots with: sessions prune --idle 2h, or raise MAX_SESSIONS",
+			}, true
+		}
+		return Result{Status: Fail, Detail: err.Error(), Hint: "check the backend logs"}, true
+	}
+	if err := rest.DeleteSession(s.ID); err != nil {
+		return Result{
+			Status: Warn,
+			Detail: "created test session " + s.ID + " but could not delete it: " + err.Error(),
+			Hint:   "delete it with: sessions delete " + s.ID,
+		}, false
+	}
+	return Result{Status: Pass, Detail: "created and deleted a test session"}, false
+}
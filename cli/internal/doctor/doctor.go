@@ -0,0 +1,125 @@
+// Package doctor diagnoses the path to a server one layer at a time, from
+// name resolution to creating a session, so a failure points at its cause.
+package doctor
+
+import (
+	"context"
+	"crypto/x509"
+	"net"
+	"net/url"
+	"time"
+)
+
+// Status is the outcome of one check.
+type Status string
+
+const (
+	Pass Status = "pass"
+	Warn Status = "warn"
+	Fail Status = "fail"
+	Skip Status = "skip"
+)
+
+// Result is the outcome of one check, with a hint on what to do about it.
+type Result struct {
+	Check      string `json:"check"`
+	Status     Status `json:"status"`
+	Detail     string `json:"detail"`
+	Hint       string `json:"hint,omitempty"`
+	DurationMs int64  `json:"durationMs"`
+}
+
+// Target is the server to diagnose. TokenErr is set when the configured
+// token could not be resolved; the checks that need it fail with it.
+type Target struct {
+	Name     string
+	URL      string
+	Token    string
+	TokenErr error
+}
+
+// Timeout bounds each network check.
+const Timeout = 5 * time.Second
+
+// checkFunc runs one check. A failed check returns stop to skip the checks
+// that depend on it.
+type checkFunc func(ctx context.Context, d *run) (r Result, stop bool)
+
+type run struct {
+	target Target
+	url    *url.URL
+	host   string
+	port   string
+	open   bool // the server accepts requests without a token
+
+	roots *x509.CertPool // trusted CAs for the tls check; nil for the system's
+}
+
+var checks = []struct {
+	name string
+	fn   checkFunc
+}{
+	{"url", checkURL},
+	{"dns", checkDNS},
+	{"tcp", checkTCP},
+	{"tls", checkTLS},
+	{"health", checkHealth},
+	{"auth", checkAuth},
+	{"websocket", checkWebSocket},
+	{"capacity", checkCapacity},
+}
+
+// Run performs every check in order. Once one fails in a way later checks
+// depend on, those are reported as skipped.
+func Run(ctx context.Context, t Target) []Result {
+	d := &run{target: t}
+	var results []Result
+	var stoppedBy string
+	for _, c := range checks {
+		if stoppedBy != "" {
+			results = append(results, Result{Check: c.name, Status: Skip, Detail: "skipped: " + stoppedBy + " failed"})
+			continue
+		}
+		start := time.Now()
+		r, stop := c.fn(ctx, d)
+		r.Check = c.name
+		r.DurationMs = time.Since(start).Milliseconds()
+		results = append(results, r)
+		if stop {
+			stoppedBy = c.name
+		}
+	}
+	return results
+}
+
+// Failed counts the failed checks.
+func Failed(results []Result) int {
+	n := 0
+	for _, r := range results {
+		if r.Status == Fail {
+			n++
+		}
+	}
+	return n
+}
+
+func checkURL(ctx context.Context, d *run) (Result, bool) {
+	u, err := url.Parse(d.target.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return Result{
+			Status: Fail,
+			Detail: "not an http:// or https:// URL: " + d.target.URL,
+			Hint:   "fix it with: servers edit " + d.target.Name + " --url https://host",
+		}, true
+	}
+	d.url = u
+	d.host = u.Hostname()
+	d.port = u.Port()
+	if d.port == "" {
+		d.port = "80"
+		if u.Scheme == "https" {
+			d.port = "443"
+		}
+	}
+	return Result{Status: Pass, Detail: u.Scheme + "://" + net.JoinHostPort(d.host, d.port)}, false
+}
@@ -0,0 +1,245 @@
+package doctor
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/arvid/remote-ai-ide/cli/internal/client"
+	"github.com/gorilla/websocket"
+)
+
+// backend fakes the server's /health, /api/sessions and /ws.
+type backend struct {
+	health  string // body of /health
+	token   string // the one token accepted
+	denied  int    // status for a missing or wrong token on /api
+	apiOpen bool   // /api accepts requests without a token
+	wsOpen  bool   // /ws accepts any token
+	wsDeny  bool   // /ws closes every connection with 4001
+}
+
+func (b *backend) serve(t *testing.T) *httptest.Server {
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/health":
+			w.Write([]byte(b.health))
+		case "/api/sessions":
+			if !b.apiOpen && r.Header.Get("Authorization") != "Bearer "+b.token {
+				w.WriteHeader(b.denied)
+				return
+			}
+			if r.Method == "POST" {
+				w.WriteHeader(http.StatusCreated)
+				json.NewEncoder(w).Encode(client.Session{ID: "s1"})
+				return
+			}
+			w.Write([]byte("[]"))
+		case "/api/sessions/s1":
+			w.WriteHeader(http.StatusNoContent)
+		case "/ws":
+			conn, err := upgrader.Upgrade(w, r, nil)
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+			if b.wsDeny || (!b.wsOpen && r.URL.Query().Get("token") != b.token) {
+				conn.WriteControl(websocket.CloseMessage,
+					websocket.FormatCloseMessage(client.CloseUnauthorized, "Unauthorized"), time.Now().Add(time.Second))
+				return
+			}
+			for {
+				if _, _, err := conn.ReadMessage(); err != nil {
+					return
+				}
+			}
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+const healthy = `{"status":"ok","timestamp":"2026-05-01T12:00:00Z","activeSessions":2}`
+
+func result(t *testing.T, results []Result, check string) Result {
+	t.Helper()
+	for _, r := range results {
+		if r.Check == check {
+			return r
+		}
+	}
+	t.Fatalf("no %s check in %+v", check, results)
+	return Result{}
+}
+
+func TestRunPasses(t *testing.T) {
+	b := &backend{health: healthy, token: "tok", denied: http.StatusUnauthorized}
+	srv := b.serve(t)
+	results := Run(context.Background(), Target{Name: "local", URL: srv.URL, Token: "tok"})
+	for _, r := range results {
+		if r.Status != Pass {
+			t.Errorf("%s: %s %q, want pass", r.Check, r.Status, r.Detail)
+		}
+	}
+	if n := Failed(results); n != 0 {
+		t.Errorf("Failed = %d, want 0", n)
+	}
+}
+
+func TestHealthPlainText(t *testing.T) {
+	b := &backend{health: "OK\n", token: "tok", denied: http.StatusUnauthorized}
+	srv := b.serve(t)
+	results := Run(context.Background(), Target{Name: "local", URL: srv.URL, Token: "tok"})
+	r := result(t, results, "health")
+	if r.Status != Warn || !strings.Contains(r.Detail, `plain-text answer "OK"`) {
+		t.Errorf("health = %s %q, want a plain-text warning", r.Status, r.Detail)
+	}
+	// A proxy answering /health does not stop the checks behind it
+	if r := result(t, results, "auth"); r.Status != Pass {
+		t.Errorf("auth = %s after a plain-text health answer, want pass", r.Status)
+	}
+}
+
+func TestAuth(t *testing.T) {
+	tests := []struct {
+		name   string
+		b      backend
+		token  string
+		status Status
+		hint   string
+	}{
+		{"401", backend{denied: http.StatusUnauthorized}, "wrong", Fail, "one of the server's AUTH_TOKENS"},
+		{"403", backend{denied: http.StatusForbidden}, "wrong", Fail, "one of the server's AUTH_TOKENS"},
+		{"proxy in the way", backend{denied: http.StatusBadGateway}, "wrong", Fail, "not reaching the backend"},
+		{"no token required", backend{apiOpen: true, wsOpen: true}, "tok", Warn, "set AUTH_TOKENS"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.b.health, tt.b.token = healthy, "tok"
+			srv := tt.b.serve(t)
+			results := Run(context.Background(), Target{Name: "local", URL: srv.URL, Token: tt.token})
+			r := result(t, results, "auth")
+			if r.Status != tt.status || !strings.Contains(r.Hint, tt.hint) {
+				t.Errorf("auth = %s, hint %q; want %s with %q", r.Status, r.Hint, tt.status, tt.hint)
+			}
+			if tt.status == Fail {
+				if r := result(t, results, "websocket"); r.Status != Skip {
+					t.Errorf("websocket = %s after auth failed, want skip", r.Status)
+				}
+			}
+		})
+	}
+}
+
+func TestWebSocket(t *testing.T) {
+	tests := []struct {
+		name   string
+		b      backend
+		status Status
+		detail string
+	}{
+		{"token closed with 4001", backend{wsDeny: true}, Fail, "closed with 4001"},
+		{"invalid token let in", backend{wsOpen: true}, Warn, "invalid token was not closed"},
+		{"invalid token closed", backend{}, Pass, "invalid tokens are closed with 4001"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.b.health, tt.b.token, tt.b.denied = healthy, "tok", http.StatusUnauthorized
+			srv := tt.b.serve(t)
+			results := Run(context.Background(), Target{Name: "local", URL: srv.URL, Token: "tok"})
+			r := result(t, results, "websocket")
+			if r.Status != tt.status || !strings.Contains(r.Detail, tt.detail) {
+				t.Errorf("websocket = %s %q, want %s with %q", r.Status, r.Detail, tt.status, tt.detail)
+			}
+		})
+	}
+}
+
+// tlsServer serves TLS with a self-signed certificate for 127.0.0.1 that
+// expires after validFor, and returns the pool trusting it.
+func tlsServer(t *testing.T, validFor time.Duration) (*httptest.Server, *x509.CertPool) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "doctor test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(validFor),
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+
+	srv := httptest.NewUnstartedServer(http.NotFoundHandler())
+	srv.TLS = &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+	return srv, pool
+}
+
+func TestTLSExpiry(t *testing.T) {
+	tests := []struct {
+		name     string
+		validFor time.Duration
+		status   Status
+	}{
+		{"expiring", 3 * 24 * time.Hour, Warn},
+		{"valid", 90 * 24 * time.Hour, Pass},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, pool := tlsServer(t, tt.validFor)
+			d := &run{target: Target{Name: "local", URL: srv.URL}, roots: pool}
+			if r, stop := checkURL(context.Background(), d); stop {
+				t.Fatalf("url: %s", r.Detail)
+			}
+			r, stop := checkTLS(context.Background(), d)
+			if stop || r.Status != tt.status {
+				t.Fatalf("tls = %s %q, want %s", r.Status, r.Detail, tt.status)
+			}
+			if tt.status == Warn && !strings.Contains(r.Hint, "renew") {
+				t.Errorf("hint %q, want a reminder to renew", r.Hint)
+			}
+		})
+	}
+
+	t.Run("untrusted", func(t *testing.T) {
+		srv, _ := tlsServer(t, 90*24*time.Hour)
+		d := &run{target: Target{Name: "local", URL: srv.URL}}
+		checkURL(context.Background(), d)
+		r, stop := checkTLS(context.Background(), d)
+		if !stop || r.Status != Fail || !strings.Contains(r.Hint, "trusted CA") {
+			t.Errorf("tls = %s, hint %q; want a failure naming the CA", r.Status, r.Hint)
+		}
+	})
+}
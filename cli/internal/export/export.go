@@ -0,0 +1,174 @@
+// Package export renders a session as Markdown, HTML or JSON for pasting
+// into pull requests and postmortems.
+package export
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/arvid/remote-ai-ide/cli/internal/client"
+	"github.com/arvid/remote-ai-ide/cli/internal/transcript"
+)
+
+// Formats lists the supported output formats, which double as file
+// extensions.
+var Formats = []string{"md", "html", "json"}
+
+// Entry is one event of the conversation. Role is user, assistant, tool,
+// permission or error; Decision is set for answered permission requests.
+type Entry struct {
+	Seq       int             `json:"seq"`
+	Timestamp time.Time       `json:"timestamp"`
+	Role      string          `json:"role"`
+	Content   string          `json:"content,omitempty"`
+	ToolName  string          `json:"toolName,omitempty"`
+	ToolInput json.RawMessage `json:"toolInput,omitempty"`
+	Decision  string          `json:"decision,omitempty"`
+}
+
+type Session struct {
+	ID          string    `json:"id"`
+	Server      string    `json:"server"`
+	ProjectPath string    `json:"projectPath,omitempty"`
+	ExportedAt  time.Time `json:"exportedAt"`
+	Entries     []Entry   `json:"entries"`
+}
+
+// Build merges the server history with the local transcript, which alone
+// knows about tool calls, permission decisions and failed turns. With a nil
+// detail, as when the server has already dropped the session, the
+// transcript supplies the messages too. Only entries after since are kept.
+func Build(id, server string, detail *client.SessionDetail, local []transcript.Message, since int) *Session {
+	s := &Session{ID: id, Server: server, ExportedAt: time.Now(), Entries: []Entry{}}
+	if detail != nil {
+		s.ProjectPath = detail.ProjectPath
+		for _, m := range detail.Messages {
+			if m.Seq <= since {
+				continue
+			}
+			s.Entries = append(s.Entries, Entry{
+				Seq:       m.Seq,
+				Timestamp: time.UnixMilli(m.Timestamp),
+				Role:      m.Role,
+				Content:   m.Content,
+			})
+		}
+	}
+
+	for _, m := range local {
+		if m.Seq <= since {
+			continue
+		}
+		if detail != nil && (m.Role == "user" || m.Role == "assistant") {
+			continue
+		}
+		e := Entry{Seq: m.Seq, Timestamp: m.Time, Role: m.Role, ToolName: m.ToolName, ToolInput: m.ToolInput}
+		if m.Role == "permission" {
+			e.Decision = m.Content
+		} else {
+			e.Content = m.Content
+		}
+		s.Entries = append(s.Entries, e)
+	}
+
+	// Server entries come first, so they stay ahead of local events that
+	// inherited their number
+	sort.SliceStable(s.Entries, func(i, j int) bool {
+		return s.Entries[i].Seq < s.Entries[j].Seq
+	})
+	return s
+}
+
+// Load fetches a session from the server and merges it with its transcript
+// under root. If the server cannot provide the session but a transcript
+// exists, the export is built from the transcript alone and fallback says
+// why.
+func Load(rest *client.RESTClient, root, server, id string, since int) (s *Session, fallback error, err error) {
+	var local []transcript.Message
+	path := transcript.Path(root, server, id)
+	entries, terr := transcript.Read(path)
+	if terr == nil {
+		local = transcript.Messages(entries)
+	} else if !errors.Is(terr, os.ErrNotExist) {
+		return nil, nil, terr
+	}
+
+	detail, err := rest.GetSession(id, since)
+	if err != nil {
+		if terr != nil {
+			return nil, nil, err
+		}
+		return Build(id, server, nil, local, since), err, nil
+	}
+	return Build(id, server, detail, local, since), nil, nil
+}
+
+// Write renders s in the given format.
+func Write(w io.Writer, s *Session, format string) error {
+	switch format {
+	case "md":
+		return writeMarkdown(w, s)
+	case "html":
+		return writeHTML(w, s)
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(s)
+	default:
+		return fmt.Errorf("unknown format %q (want md, html or json)", format)
+	}
+}
+
+// FileName is the default name for an export of s.
+func FileName(s *Session, format string) string {
+	id := s.ID
+	if len(id) > 8 {
+		id = id[:8]
+	}
+	return fmt.Sprintf("session-%s-%s.%s", id, s.ExportedAt.Format("20060102-150405"), format)
+}
+
+func formatTime(t time.Time) string {
+	return t.Local().Format("2006-01-02 15:04:05 MST")
+}
+
+// indentInput pretty-prints a tool input, leaving invalid JSON as is.
+func indentInput(raw json.RawMessage) string {
+	var v interface{}
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return string(raw)
+	}
+	out, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return string(raw)
+	}
+	return string(out)
+}
+
+func decision(e Entry) string {
+	if e.Decision == "" {
+		return "unanswered"
+	}
+	return e.Decision
+}
+
+func title(role string) string {
+	switch role {
+	case "user":
+		return "User"
+	case "assistant":
+		return "Assistant"
+	case "tool":
+		return "Tool call"
+	case "permission":
+		return "Permission"
+	case "error":
+		return "Error"
+	}
+	return role
+}
@@ -0,0 +1,160 @@
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/arvid/remote-ai-ide/cli/internal/client"
+	"github.com/arvid/remote-ai-ide/cli/internal/transcript"
+)
+
+var t0 = time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+
+// testDetail is the server's history of a two-turn session.
+func testDetail() *client.SessionDetail {
+	return &client.SessionDetail{
+		Session: client.Session{ID: "abcdef1234", ProjectPath: "/workspace/api"},
+		Messages: []client.HistoryMessage{
+			{Role: "user", Content: "list files", Seq: 1, Timestamp: t0.UnixMilli()},
+			{Role: "assistant", Content: "Here they are.", Seq: 3, Timestamp: t0.Add(time.Second).UnixMilli()},
+			{Role: "user", Content: "delete them", Seq: 4, Timestamp: t0.Add(2 * time.Second).UnixMilli()},
+			{Role: "assistant", Content: "Done.", Seq: 6, Timestamp: t0.Add(3 * time.Second).UnixMilli()},
+		},
+	}
+}
+
+// testLocal is the transcript of the same session: the messages again,
+// plus what only the client saw.
+var testLocal = []transcript.Message{
+	{Time: t0, Seq: 1, Role: "user", Content: "list files"},
+	{Time: t0, Seq: 2, Role: "tool", ToolName: "Bash", ToolInput: json.RawMessage(`{"command":"ls"}`)},
+	{Time: t0, Seq: 3, Role: "assistant", Content: "Here they are."},
+	{Time: t0, Seq: 4, Role: "user", Content: "delete them"},
+	{Time: t0, Seq: 5, Role: "permission", ToolName: "Bash", ToolInput: json.RawMessage(`{"command":"rm *"}`), Content: "denied"},
+	{Time: t0, Seq: 6, Role: "assistant", Content: "Done."},
+	{Time: t0, Seq: 6, Role: "error", Content: "Rate limit exceeded"},
+}
+
+func roles(s *Session) string {
+	var r []string
+	for _, e := range s.Entries {
+		r = append(r, e.Role)
+	}
+	return strings.Join(r, " ")
+}
+
+func TestBuild(t *testing.T) {
+	tests := []struct {
+		name   string
+		detail *client.SessionDetail
+		since  int
+		roles  string
+	}{
+		{"merged", testDetail(), 0, "user tool assistant user permission assistant error"},
+		{"transcript only", nil, 0, "user tool assistant user permission assistant error"},
+		{"since", testDetail(), 3, "user permission assistant error"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := Build("abcdef1234", "work", tt.detail, testLocal, tt.since)
+			if got := roles(s); got != tt.roles {
+				t.Errorf("entries %q, want %q", got, tt.roles)
+			}
+		})
+	}
+
+	s := Build("abcdef1234", "work", testDetail(), testLocal, 0)
+	if s.ProjectPath != "/workspace/api" {
+		t.Errorf("project %q, want the server's", s.ProjectPath)
+	}
+	if e := s.Entries[2]; !e.Timestamp.Equal(t0.Add(time.Second)) {
+		t.Errorf("assistant message at %s, want the server's time", e.Timestamp)
+	}
+	if e := s.Entries[4]; e.Decision != "denied" || e.Content != "" {
+		t.Errorf("permission entry %+v, want the decision apart from the content", e)
+	}
+}
+
+func TestWriteMarkdown(t *testing.T) {
+	s := Build("abcdef1234", "work", testDetail(), testLocal, 0)
+	s.Entries = append(s.Entries, Entry{Seq: 7, Timestamp: t0, Role: "tool", ToolName: "Write",
+		ToolInput: json.RawMessage(`{"content":"a ` + "```" + ` fence"}`)})
+	var b bytes.Buffer
+	if err := Write(&b, s, "md"); err != nil {
+		t.Fatal(err)
+	}
+	out := b.String()
+	for _, want := range []string{
+		"# Session abcdef1234\n",
+		"- **Project:** `/workspace/api`\n",
+		"## User · ",
+		"\nlist files\n",
+		"**Tool call** `Bash` · ",
+		"```json\n{\n  \"command\": \"ls\"\n}\n```\n",
+		"**Permission** `Bash` denied · ",
+		"> Rate limit exceeded\n",
+		// A fence inside the input needs a longer one around it
+		"````json\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("markdown lacks %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestWriteHTML(t *testing.T) {
+	s := &Session{ID: "abcdef1234", Server: "work", ExportedAt: t0, Entries: []Entry{
+		{Seq: 1, Timestamp: t0, Role: "user", Content: "look at **this**\n\n<script>alert(1)</script>"},
+		{Seq: 2, Timestamp: t0, Role: "assistant", Content: "inline <img src=x onerror=alert(2)> too"},
+		{Seq: 3, Timestamp: t0, Role: "tool", ToolName: "Bash", ToolInput: json.RawMessage(`{"command":"echo <b>"}`)},
+		{Seq: 4, Timestamp: t0, Role: "error", Content: "<script>alert(3)</script>"},
+	}}
+	var b bytes.Buffer
+	if err := Write(&b, s, "html"); err != nil {
+		t.Fatal(err)
+	}
+	out := b.String()
+	for _, bad := range []string{"<script>", "<img", "<b>"} {
+		if strings.Contains(out, bad) {
+			t.Errorf("html carries %q from a message:\n%s", bad, out)
+		}
+	}
+	for _, want := range []string{
+		"<title>Session abcdef1234</title>",
+		"<strong>this</strong>",
+		`<section class="tool">`,
+		"&lt;script&gt;alert(3)&lt;/script&gt;",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("html lacks %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	s := Build("abcdef1234", "work", testDetail(), testLocal, 0)
+	var b bytes.Buffer
+	if err := Write(&b, s, "json"); err != nil {
+		t.Fatal(err)
+	}
+	var back Session
+	if err := json.Unmarshal(b.Bytes(), &back); err != nil {
+		t.Fatalf("export is not JSON: %v", err)
+	}
+	if back.ID != s.ID || roles(&back) != roles(s) {
+		t.Errorf("read back %s with %q, want %s with %q", back.ID, roles(&back), s.ID, roles(s))
+	}
+	var input bytes.Buffer
+	if err := json.Compact(&input, back.Entries[1].ToolInput); err != nil || input.String() != `{"command":"ls"}` {
+		t.Errorf("tool input %s, want it kept as JSON", back.Entries[1].ToolInput)
+	}
+}
+
+func TestWriteUnknownFormat(t *testing.T) {
+	if err := Write(&bytes.Buffer{}, &Session{}, "pdf"); err == nil {
+		t.Error("Write accepted format pdf")
+	}
+}
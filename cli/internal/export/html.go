@@ -0,0 +1,77 @@
+package export
+
+import (
+	"bytes"
+	"html/template"
+	"io"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+)
+
+// Raw HTML in messages is left out by goldmark's default renderer, so the
+// page cannot run anything a message smuggles in.
+var md = goldmark.New(goldmark.WithExtensions(extension.GFM))
+
+var page = template.Must(template.New("session").Funcs(template.FuncMap{
+	"markdown": func(s string) template.HTML {
+		var buf bytes.Buffer
+		if err := md.Convert([]byte(s), &buf); err != nil {
+			return template.HTML(template.HTMLEscapeString(s))
+		}
+		return template.HTML(buf.String())
+	},
+	"time":     formatTime,
+	"title":    title,
+	"input":    indentInput,
+	"decision": decision,
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Session {{.ID}}</title>
+<style>
+body { font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", sans-serif; max-width: 50rem; margin: 2rem auto; padding: 0 1rem; color: #1f2328; }
+header dl { display: grid; grid-template-columns: max-content auto; gap: .25rem 1rem; }
+header dt { font-weight: 600; }
+section { border-left: 4px solid #d0d7de; padding: .25rem 1rem; margin: 1rem 0; }
+section.user { border-color: #0969da; }
+section.assistant { border-color: #8250df; }
+section.tool, section.permission { border-color: #bf8700; font-size: .9em; }
+section.error { border-color: #cf222e; }
+h2 { font-size: 1rem; margin: .5rem 0; }
+h2 time { font-weight: normal; color: #656d76; margin-left: .5rem; }
+pre { background: #f6f8fa; padding: .75rem; overflow-x: auto; }
+code { font-family: ui-monospace, SFMono-Regular, Menlo, monospace; }
+</style>
+</head>
+<body>
+<header>
+<h1>Session {{.ID}}</h1>
+<dl>
+<dt>Server</dt><dd>{{.Server}}</dd>
+{{- if .ProjectPath}}
+<dt>Project</dt><dd><code>{{.ProjectPath}}</code></dd>
+{{- end}}
+<dt>Exported</dt><dd>{{time .ExportedAt}}</dd>
+</dl>
+</header>
+{{- range .Entries}}
+<section class="{{.Role}}">
+<h2>{{title .Role}}{{if .ToolName}} <code>{{.ToolName}}</code>{{end}}{{if eq .Role "permission"}} {{decision .}}{{end}}<time>{{time .Timestamp}}</time></h2>
+{{- if .ToolName}}
+<pre><code>{{input .ToolInput}}</code></pre>
+{{- else if eq .Role "error"}}
+<p>{{.Content}}</p>
+{{- else}}
+{{markdown .Content}}
+{{- end}}
+</section>
+{{- end}}
+</body>
+</html>
+`))
+
+func writeHTML(w io.Writer, s *Session) error {
+	return page.Execute(w, s)
+}
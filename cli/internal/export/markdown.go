@@ -0,0 +1,50 @@
+package export
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+func writeMarkdown(w io.Writer, s *Session) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Session %s\n\n", s.ID)
+	fmt.Fprintf(&b, "- **Server:** %s\n", s.Server)
+	if s.ProjectPath != "" {
+		fmt.Fprintf(&b, "- **Project:** `%s`\n", s.ProjectPath)
+	}
+	fmt.Fprintf(&b, "- **Exported:** %s\n", formatTime(s.ExportedAt))
+
+	for _, e := range s.Entries {
+		ts := formatTime(e.Timestamp)
+		switch e.Role {
+		case "tool":
+			fmt.Fprintf(&b, "\n**%s** `%s` · %s\n\n", title(e.Role), e.ToolName, ts)
+			writeFence(&b, "json", indentInput(e.ToolInput))
+		case "permission":
+			fmt.Fprintf(&b, "\n**%s** `%s` %s · %s\n\n", title(e.Role), e.ToolName, decision(e), ts)
+			writeFence(&b, "json", indentInput(e.ToolInput))
+		case "error":
+			fmt.Fprintf(&b, "\n**%s** · %s\n\n> %s\n", title(e.Role), ts, strings.ReplaceAll(e.Content, "\n", "\n> "))
+		default:
+			fmt.Fprintf(&b, "\n## %s · %s\n\n%s\n", title(e.Role), ts, strings.TrimSpace(e.Content))
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// writeFence wraps body in a code fence longer than any backtick run in it.
+func writeFence(b *strings.Builder, lang, body string) {
+	longest, run := 0, 0
+	for _, c := range body {
+		if c == '`' {
+			run++
+			longest = max(longest, run)
+		} else {
+			run = 0
+		}
+	}
+	fence := strings.Repeat("`", max(3, longest+1))
+	fmt.Fprintf(b, "%s%s\n%s\n%s\n", fence, lang, body, fence)
+}
@@ -0,0 +1,308 @@
+// Package outbox keeps messages typed while the connection is down on disk,
+// so they survive a restart and are sent once the session is reachable.
+package outbox
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Message is a user message waiting to be sent.
+type Message struct {
+	ID        int       `json:"id"`
+	SessionID string    `json:"sessionId"`
+	Text      string    `json:"text"`
+	Seq       int       `json:"seq,omitempty"` // the number it was sent with, if it was
+	Created   time.Time `json:"created"`
+	// Owner is the process that wrote the message, or last took it for
+	// sending. Others leave it alone while that process runs.
+	Owner int `json:"owner,omitempty"`
+}
+
+// Outbox holds the pending messages for one server, in the order they
+// were written. It is safe for concurrent use, and by several processes:
+// each change locks the file, reads it afresh and saves it before
+// unlocking, and a process only sends the messages it owns.
+type Outbox struct {
+	mu       sync.Mutex
+	path     string
+	pid      int
+	nextID   int
+	messages []Message
+	sending  map[int]bool // taken for sending, kept until the write succeeds
+	dirty    bool         // holds changes the file lacks
+}
+
+// lockWait bounds how long a change waits for another process to release
+// the file. The TUI changes the outbox from its event loop, which must not
+// stall behind another connect; past it the change is kept in memory.
+const lockWait = 100 * time.Millisecond
+
+// errLocked reports that another process holds the outbox file.
+var errLocked = errors.New("another process has the outbox locked")
+
+type file struct {
+	NextID   int       `json:"nextId"`
+	Messages []Message `json:"messages"`
+}
+
+// New returns an outbox that is kept in memory only.
+func New() *Outbox {
+	return &Outbox{pid: os.Getpid(), nextID: 1, sending: make(map[int]bool)}
+}
+
+// Path returns the outbox file of a server, beside its transcripts.
+func Path(root, server string) string {
+	return filepath.Join(root, url.PathEscape(server), "outbox.json")
+}
+
+// Open loads the outbox at path; a missing file is an empty outbox. The
+// returned Outbox is usable even with an error, starting empty.
+func Open(path string) (*Outbox, error) {
+	o := &Outbox{path: path, pid: os.Getpid(), nextID: 1, sending: make(map[int]bool)}
+	return o, o.change(func() bool { return false })
+}
+
+// change applies fn to the outbox as it is on disk, holding the file lock
+// so that no other process writes in between, and saves it if fn reports
+// a change. Without the file the change is kept in memory, to be saved
+// by a later one. Must hold o.mu.
+func (o *Outbox) change(fn func() bool) error {
+	if o.path == "" {
+		fn()
+		return nil
+	}
+	unlock, err := o.lock()
+	if err != nil {
+		if fn() {
+			o.dirty = true
+		}
+		return fmt.Errorf("locking outbox: %w", err)
+	}
+	defer unlock()
+	loadErr := o.load()
+	if !fn() && !o.dirty {
+		return loadErr
+	}
+	if err := o.save(); err != nil {
+		o.dirty = true
+		return err
+	}
+	o.dirty = false
+	return loadErr
+}
+
+func (o *Outbox) lock() (func(), error) {
+	if err := os.MkdirAll(filepath.Dir(o.path), 0700); err != nil {
+		return nil, err
+	}
+	deadline := time.Now().Add(lockWait)
+	for {
+		unlock, err := tryLockFile(o.path + ".lock")
+		if !errors.Is(err, errLocked) || time.Now().After(deadline) {
+			return unlock, err
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// load reads the file into o; a missing file is an empty outbox. Messages
+// of ours not yet saved are kept, and an unreadable file leaves o as it
+// was. Must hold o.mu and the file lock.
+func (o *Outbox) load() error {
+	var f file
+	data, err := os.ReadFile(o.path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return fmt.Errorf("reading outbox: %w", err)
+	default:
+		if err := json.Unmarshal(data, &f); err != nil {
+			return fmt.Errorf("parsing outbox %s: %w", o.path, err)
+		}
+	}
+	if o.dirty {
+		for _, m := range o.messages {
+			if m.Owner == o.pid && !slices.ContainsFunc(f.Messages, func(s Message) bool { return s.ID == m.ID }) {
+				f.Messages = append(f.Messages, m)
+			}
+		}
+	}
+	o.messages = f.Messages
+	o.nextID = max(f.NextID, o.nextID, 1)
+	return nil
+}
+
+// mine reports whether this process may send m: it wrote or took it, or
+// whoever did has ended. Must hold o.mu.
+func (o *Outbox) mine(m Message) bool {
+	return m.Owner == 0 || m.Owner == o.pid || !processAlive(m.Owner)
+}
+
+// Add queues text for sessionID. The message is kept even if saving fails.
+func (o *Outbox) Add(sessionID, text string) (Message, error) {
+	return o.Readd(sessionID, text, 0)
+}
+
+// Readd queues again a message the server turned away, keeping the seq it
+// was sent with so the resend reads as the same message.
+func (o *Outbox) Readd(sessionID, text string, seq int) (Message, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	m := Message{SessionID: sessionID, Text: text, Seq: seq, Created: time.Now(), Owner: o.pid}
+	err := o.change(func() bool {
+		m.ID = o.nextID
+		o.nextID++
+		o.messages = append(o.messages, m)
+		return true
+	})
+	return m, err
+}
+
+// Session returns the messages waiting for sessionID that this process
+// may send, oldest first. Ones taken for sending are left out.
+func (o *Outbox) Session(sessionID string) []Message {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.change(func() bool { return false })
+	var out []Message
+	for _, m := range o.messages {
+		if m.SessionID == sessionID && !o.sending[m.ID] && o.mine(m) {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+// Others counts the messages pending for sessions other than sessionIDs.
+func (o *Outbox) Others(sessionIDs ...string) int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.change(func() bool { return false })
+	n := 0
+	for _, m := range o.messages {
+		if !slices.Contains(sessionIDs, m.SessionID) {
+			n++
+		}
+	}
+	return n
+}
+
+// Update replaces the text of message id.
+func (o *Outbox) Update(id int, text string) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	found := false
+	err := o.change(func() bool {
+		i := o.index(id)
+		if found = i >= 0; found {
+			o.messages[i].Text = text
+		}
+		return found
+	})
+	if err == nil && !found {
+		err = fmt.Errorf("no pending message %d", id)
+	}
+	return err
+}
+
+// Take marks message id as being sent, claiming it from other processes.
+// It stays on disk, so it is sent again should the process end before
+// Remove. Take reports false if the message is gone or another process
+// has it.
+func (o *Outbox) Take(id int) bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	taken := false
+	// Without the file no other process can claim it either, so a failed
+	// change still takes it; one locked by another process may be claiming
+	// it, and the next flush tries again
+	err := o.change(func() bool {
+		i := o.index(id)
+		if i < 0 || o.sending[id] || !o.mine(o.messages[i]) {
+			return false
+		}
+		taken = true
+		if o.messages[i].Owner == o.pid {
+			return false
+		}
+		o.messages[i].Owner = o.pid
+		return true
+	})
+	if errors.Is(err, errLocked) {
+		return false
+	}
+	if taken {
+		o.sending[id] = true
+	}
+	return taken
+}
+
+// Return puts message id, whose write failed, back among those waiting.
+func (o *Outbox) Return(id int) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	delete(o.sending, id)
+}
+
+// Remove drops message id, once sent or discarded.
+func (o *Outbox) Remove(id int) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	delete(o.sending, id)
+	return o.change(func() bool {
+		i := o.index(id)
+		if i < 0 {
+			return false
+		}
+		o.messages = slices.Delete(o.messages, i, i+1)
+		return true
+	})
+}
+
+// index finds message id. Must hold o.mu.
+func (o *Outbox) index(id int) int {
+	return slices.IndexFunc(o.messages, func(m Message) bool { return m.ID == id })
+}
+
+// save writes the outbox through a temporary file, so a crash leaves
+// either the old or the new contents. An empty outbox removes the file.
+// Must hold o.mu and the file lock.
+func (o *Outbox) save() error {
+	if o.path == "" {
+		return nil
+	}
+	if len(o.messages) == 0 {
+		if err := os.Remove(o.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("saving outbox: %w", err)
+		}
+		return nil
+	}
+	data, err := json.MarshalIndent(file{NextID: o.nextID, Messages: o.messages}, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(o.path), ".outbox-*")
+	if err != nil {
+		return fmt.Errorf("saving outbox: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("saving outbox: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("saving outbox: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), o.path); err != nil {
+		return fmt.Errorf("saving outbox: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package outbox
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestOutboxShared(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "outbox.json")
+	a, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Each sees the other's writes instead of saving over them
+	if _, err := a.Add("s", "from a"); err != nil {
+		t.Fatal(err)
+	}
+	mb, err := b.Add("s", "from b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mb.ID != 2 {
+		t.Errorf("second message got ID %d, want 2", mb.ID)
+	}
+	if got := a.Session("s"); len(got) != 2 || got[1].Text != "from b" {
+		t.Fatalf("a sees %+v, want both messages", got)
+	}
+
+	if err := a.Remove(1); err != nil {
+		t.Fatal(err)
+	}
+	if got := b.Session("s"); len(got) != 1 || got[0].ID != 2 {
+		t.Errorf("b sees %+v after a removed its message, want only b's", got)
+	}
+}
+
+func TestOutboxOwner(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "outbox.json")
+	running := os.Getppid()
+	ended := 1 << 30
+	data, err := json.Marshal(file{NextID: 3, Messages: []Message{
+		{ID: 1, SessionID: "s", Text: "another connect's", Created: time.Now(), Owner: running},
+		{ID: 2, SessionID: "s", Text: "left over", Created: time.Now(), Owner: ended},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	o, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := o.Session("s")
+	if len(got) != 1 || got[0].ID != 2 {
+		t.Fatalf("Session = %+v, want only the message of the ended process", got)
+	}
+	if o.Take(1) {
+		t.Error("took a message of a running process")
+	}
+	if !o.Take(2) {
+		t.Fatal("could not take the message of an ended process")
+	}
+	if o.Take(2) {
+		t.Error("took a message twice")
+	}
+
+	// The claim is on disk for other processes to see
+	other, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other.pid = running
+	if got := other.Session("s"); len(got) != 1 || got[0].ID != 1 {
+		t.Errorf("another process sees %+v, want only its own message", got)
+	}
+}
+
+func TestOutboxLocked(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "outbox.json")
+	o, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := o.Add("s", "first"); err != nil {
+		t.Fatal(err)
+	}
+	unlock, err := tryLockFile(path + ".lock")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Another process holding the file delays a change only briefly
+	start := time.Now()
+	if _, err := o.Add("s", "second"); !errors.Is(err, errLocked) {
+		t.Errorf("Add while locked = %v, want errLocked", err)
+	}
+	if waited := time.Since(start); waited > 5*lockWait {
+		t.Errorf("Add waited %v for the lock", waited)
+	}
+	if o.Take(1) {
+		t.Error("took a message while another process had the file")
+	}
+	if got := o.Session("s"); len(got) != 2 {
+		t.Fatalf("Session = %+v, want the unsaved message kept", got)
+	}
+
+	unlock()
+	if !o.Take(1) {
+		t.Error("could not take a message once the file was released")
+	}
+	other, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := other.Session("s"); len(got) != 2 || got[1].Text != "second" {
+		t.Errorf("file holds %+v, want the message added while locked", got)
+	}
+}
@@ -0,0 +1,211 @@
+// Package policy decides permission requests from configured rules.
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/arvid/remote-ai-ide/cli/internal/config"
+)
+
+type Action string
+
+const (
+	Allow Action = "allow"
+	Deny  Action = "deny"
+	Ask   Action = "ask"
+)
+
+// Decision is the outcome for one permission request. Rule describes the
+// rule that fired and is empty when no rule matched.
+type Decision struct {
+	Action Action
+	Rule   string
+}
+
+// Scope is a named list of rules, such as those of one server or project.
+type Scope struct {
+	Name  string
+	Rules []config.PermissionRule
+}
+
+type rule struct {
+	desc   string
+	action Action
+	tool   *regexp.Regexp
+	input  map[string]inputPattern
+}
+
+type inputPattern struct {
+	re      *regexp.Regexp
+	written string // as in the config, to see which operators it allows
+}
+
+// Policy evaluates rules from several scopes. A matching deny rule in any
+// scope wins; otherwise the first matching allow or ask rule decides, with
+// scopes checked in the order given. Without a match the answer is Ask.
+type Policy struct {
+	rules []rule
+}
+
+// New compiles the scopes, most specific first.
+func New(scopes ...Scope) (*Policy, error) {
+	p := &Policy{}
+	for _, sc := range scopes {
+		for i, r := range sc.Rules {
+			compiled, err := compile(r)
+			if err != nil {
+				return nil, fmt.Errorf("%s permission rule %d: %w", sc.Name, i+1, err)
+			}
+			compiled.desc = fmt.Sprintf("%s #%d: %s", sc.Name, i+1, describe(r))
+			p.rules = append(p.rules, compiled)
+		}
+	}
+	return p, nil
+}
+
+// Decide returns the decision for a tool call. A nil Policy always asks.
+func (p *Policy) Decide(toolName string, toolInput json.RawMessage) Decision {
+	if p == nil {
+		return Decision{Action: Ask}
+	}
+
+	var fields map[string]interface{}
+	_ = json.Unmarshal(toolInput, &fields)
+
+	var first *rule
+	for i := range p.rules {
+		r := &p.rules[i]
+		if !r.matches(toolName, fields) {
+			continue
+		}
+		if r.action == Deny {
+			return Decision{Action: Deny, Rule: r.desc}
+		}
+		if first == nil {
+			first = r
+		}
+	}
+	if first != nil {
+		return Decision{Action: first.action, Rule: first.desc}
+	}
+	return Decision{Action: Ask}
+}
+
+func (r *rule) matches(toolName string, fields map[string]interface{}) bool {
+	if !r.tool.MatchString(toolName) {
+		return false
+	}
+	for key, p := range r.input {
+		v, ok := fields[key]
+		if !ok {
+			return false
+		}
+		s := fieldString(v)
+		if !p.re.MatchString(s) {
+			return false
+		}
+		// A deny rule should catch a command wherever it is chained, but
+		// allowing or asking about one must not cover what follows it
+		if r.action != Deny && chains(p.written, s) {
+			return false
+		}
+	}
+	return true
+}
+
+// shellOperators run or feed another command after the one a rule names.
+var shellOperators = []string{";", "&&", "||", "|", "`", "$(", "\n"}
+
+// chains reports whether value holds a shell operator that the pattern
+// written for it does not.
+func chains(written, value string) bool {
+	for _, op := range shellOperators {
+		if strings.Contains(value, op) && !strings.Contains(written, op) {
+			return true
+		}
+	}
+	return false
+}
+
+func fieldString(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+func compile(r config.PermissionRule) (rule, error) {
+	action := Action(r.Action)
+	switch action {
+	case Allow, Deny, Ask:
+	default:
+		return rule{}, fmt.Errorf("action must be allow, deny or ask, got %q", r.Action)
+	}
+	if r.Tool == "" {
+		return rule{}, fmt.Errorf("tool is required")
+	}
+
+	tool, err := compilePattern(r.Tool)
+	if err != nil {
+		return rule{}, err
+	}
+	input := make(map[string]inputPattern, len(r.Input))
+	for key, pattern := range r.Input {
+		// An allow rule's regexp must match the whole value, so it does
+		// not approve everything that merely contains it
+		if expr, ok := strings.CutPrefix(pattern, "re:"); ok && action == Allow {
+			pattern = "re:^(?:" + expr + ")$"
+		}
+		re, err := compilePattern(pattern)
+		if err != nil {
+			return rule{}, fmt.Errorf("input %s: %w", key, err)
+		}
+		input[key] = inputPattern{re: re, written: pattern}
+	}
+	return rule{action: action, tool: tool, input: input}, nil
+}
+
+// compilePattern turns a glob into an anchored regexp. A "re:" pattern is
+// compiled as written, so it matches anywhere unless it anchors itself;
+// compile anchors those of allow rules.
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	if expr, ok := strings.CutPrefix(pattern, "re:"); ok {
+		return regexp.Compile(expr)
+	}
+	var b strings.Builder
+	b.WriteString("^")
+	for _, c := range pattern {
+		switch c {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+	return regexp.Compile(b.String())
+}
+
+func describe(r config.PermissionRule) string {
+	s := r.Action + " " + r.Tool
+	if len(r.Input) == 0 {
+		return s
+	}
+	keys := make([]string, 0, len(r.Input))
+	for k := range r.Input {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = k + "=" + r.Input[k]
+	}
+	return s + " (" + strings.Join(parts, ", ") + ")"
+}
@@ -0,0 +1,194 @@
+package secret
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"filippo.io/age"
+	"golang.org/x/term"
+)
+
+// PassphraseEnv supplies the file store's passphrase without a prompt.
+const PassphraseEnv = "REMOTE_AI_IDE_PASSPHRASE"
+
+// fileStore keeps secrets as a JSON object in a file encrypted with an
+// age scrypt passphrase. The file is read once and rewritten on change.
+type fileStore struct {
+	path       string
+	passphrase string
+	secrets    map[string]string
+}
+
+func (s *fileStore) Name() string { return s.path }
+
+func (s *fileStore) Get(key string) (string, error) {
+	if err := s.load(); errors.Is(err, fs.ErrNotExist) {
+		return "", ErrNotFound
+	} else if err != nil {
+		return "", err
+	}
+	v, ok := s.secrets[key]
+	if !ok {
+		return "", ErrNotFound
+	}
+	return v, nil
+}
+
+func (s *fileStore) Set(key, value string) error {
+	if err := s.load(); errors.Is(err, fs.ErrNotExist) {
+		// Created now, under a new passphrase
+		pass, err := passphrase("New passphrase for "+s.path+": ", true)
+		if err != nil {
+			return err
+		}
+		s.passphrase = pass
+		s.secrets = map[string]string{}
+	} else if err != nil {
+		return err
+	}
+	s.secrets[key] = value
+	return s.save()
+}
+
+func (s *fileStore) Delete(key string) error {
+	if err := s.load(); errors.Is(err, fs.ErrNotExist) {
+		return ErrNotFound
+	} else if err != nil {
+		return err
+	}
+	if _, ok := s.secrets[key]; !ok {
+		return ErrNotFound
+	}
+	delete(s.secrets, key)
+	return s.save()
+}
+
+// load reads and decrypts the file, asking for its passphrase. A missing
+// file is reported as fs.ErrNotExist without asking.
+func (s *fileStore) load() error {
+	if s.secrets != nil {
+		return nil
+	}
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return fmt.Errorf("reading secrets: %w", err)
+	}
+	pass, err := passphrase("Passphrase for "+s.path+": ", false)
+	if err != nil {
+		return err
+	}
+	id, err := age.NewScryptIdentity(pass)
+	if err != nil {
+		return err
+	}
+	r, err := age.Decrypt(bytes.NewReader(data), id)
+	if err != nil {
+		var noMatch *age.NoIdentityMatchError
+		if errors.As(err, &noMatch) {
+			return fmt.Errorf("decrypting %s: wrong passphrase", s.path)
+		}
+		return fmt.Errorf("decrypting %s: %w", s.path, err)
+	}
+	plain, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("decrypting %s: %w", s.path, err)
+	}
+	secrets := map[string]string{}
+	if err := json.Unmarshal(plain, &secrets); err != nil {
+		return fmt.Errorf("parsing %s: %w", s.path, err)
+	}
+	s.passphrase = pass
+	s.secrets = secrets
+	return nil
+}
+
+func (s *fileStore) save() error {
+	plain, err := json.Marshal(s.secrets)
+	if err != nil {
+		return err
+	}
+	rcpt, err := age.NewScryptRecipient(s.passphrase)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	w, err := age.Encrypt(&buf, rcpt)
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(plain); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	// Write beside the target and rename, so a failed write never
+	// leaves a truncated file behind
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), ".secrets-*")
+	if err != nil {
+		return fmt.Errorf("writing secrets: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(buf.Bytes()); err != nil {
+		tmp.Close()
+		return fmt.Errorf("writing secrets: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("writing secrets: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("writing secrets: %w", err)
+	}
+	return nil
+}
+
+// passphrase reads the passphrase from PassphraseEnv or the terminal,
+// asking twice when confirm is set.
+func passphrase(prompt string, confirm bool) (string, error) {
+	if p := os.Getenv(PassphraseEnv); p != "" {
+		return p, nil
+	}
+	// stdin may carry a piped token, so prompt on the terminal itself
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		if !term.IsTerminal(int(os.Stdin.Fd())) {
+			return "", fmt.Errorf("no keyring available and no terminal to ask for a passphrase; set %s", PassphraseEnv)
+		}
+		tty = os.Stdin
+	} else {
+		defer tty.Close()
+	}
+	read := func(prompt string) (string, error) {
+		fmt.Fprint(os.Stderr, prompt)
+		p, err := term.ReadPassword(int(tty.Fd()))
+		fmt.Fprintln(os.Stderr)
+		return string(p), err
+	}
+	p, err := read(prompt)
+	if err != nil {
+		return "", fmt.Errorf("reading passphrase: %w", err)
+	}
+	if p == "" {
+		return "", fmt.Errorf("empty passphrase")
+	}
+	if confirm {
+		again, err := read("Repeat passphrase: ")
+		if err != nil {
+			return "", fmt.Errorf("reading passphrase: %w", err)
+		}
+		if again != p {
+			return "", fmt.Errorf("passphrases do not match")
+		}
+	}
+	return p, nil
+}
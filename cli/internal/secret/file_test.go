@@ -0,0 +1,72 @@
+package secret
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFileStore(t *testing.T) {
+	t.Setenv(PassphraseEnv, "correct horse")
+	path := filepath.Join(t.TempDir(), "secrets.age")
+
+	s := &fileStore{path: path}
+	if err := s.Set("work", "tok-1"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if err := s.Set("home", "tok-2"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "tok-1") {
+		t.Error("the file holds the token in plain text")
+	}
+
+	// A fresh store reads the file back
+	s = &fileStore{path: path}
+	if v, err := s.Get("work"); err != nil || v != "tok-1" {
+		t.Errorf("Get(work) = %q, %v; want tok-1", v, err)
+	}
+	if err := s.Delete("work"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if err := s.Delete("work"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("second Delete = %v, want ErrNotFound", err)
+	}
+
+	s = &fileStore{path: path}
+	if _, err := s.Get("work"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get of a deleted secret = %v, want ErrNotFound", err)
+	}
+	if v, err := s.Get("home"); err != nil || v != "tok-2" {
+		t.Errorf("Get(home) = %q, %v; want tok-2", v, err)
+	}
+
+	t.Setenv(PassphraseEnv, "wrong")
+	s = &fileStore{path: path}
+	if _, err := s.Get("home"); err == nil || !strings.Contains(err.Error(), "wrong passphrase") {
+		t.Errorf("Get with the wrong passphrase = %v, want a wrong passphrase error", err)
+	}
+}
+
+func TestFileStoreMissingFile(t *testing.T) {
+	// No passphrase at hand: looking up or deleting must not ask for one
+	t.Setenv(PassphraseEnv, "")
+	path := filepath.Join(t.TempDir(), "secrets.age")
+
+	s := &fileStore{path: path}
+	if _, err := s.Get("work"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get without a file = %v, want ErrNotFound", err)
+	}
+	if err := s.Delete("work"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Delete without a file = %v, want ErrNotFound", err)
+	}
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("the file was created: %v", err)
+	}
+}
@@ -0,0 +1,85 @@
+package secret
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// Token reference prefixes understood by Resolve.
+const (
+	EnvPrefix    = "env:"
+	CmdPrefix    = "cmd:"
+	SecretPrefix = "secret:"
+)
+
+// IsReference reports whether token names where the token lives rather
+// than being the token itself.
+func IsReference(token string) bool {
+	return strings.HasPrefix(token, EnvPrefix) ||
+		strings.HasPrefix(token, CmdPrefix) ||
+		strings.HasPrefix(token, SecretPrefix)
+}
+
+// Resolve expands a token reference: "env:VAR" reads an environment
+// variable, "cmd:..." runs a shell command and takes the first line it
+// prints, and "secret:NAME" looks NAME up in the store returned by open.
+// Anything else is a literal token and is returned as is.
+func Resolve(token string, open func() Store) (string, error) {
+	switch {
+	case strings.HasPrefix(token, EnvPrefix):
+		name := strings.TrimPrefix(token, EnvPrefix)
+		v := os.Getenv(name)
+		if v == "" {
+			return "", fmt.Errorf("environment variable %s is not set", name)
+		}
+		return v, nil
+
+	case strings.HasPrefix(token, CmdPrefix):
+		return runCommand(strings.TrimSpace(strings.TrimPrefix(token, CmdPrefix)))
+
+	case strings.HasPrefix(token, SecretPrefix):
+		name := strings.TrimPrefix(token, SecretPrefix)
+		store := open()
+		v, err := store.Get(name)
+		if errors.Is(err, ErrNotFound) {
+			return "", fmt.Errorf("no secret %q in %s", name, store.Name())
+		}
+		if err != nil {
+			return "", fmt.Errorf("reading secret %q: %w", name, err)
+		}
+		return v, nil
+	}
+	return token, nil
+}
+
+// runCommand runs a token command such as "pass show remote-ai-ide". It
+// keeps the terminal on stdin and stderr so the command can prompt.
+func runCommand(command string) (string, error) {
+	if command == "" {
+		return "", fmt.Errorf("empty token command")
+	}
+	var c *exec.Cmd
+	if runtime.GOOS == "windows" {
+		c = exec.Command("cmd", "/C", command)
+	} else {
+		c = exec.Command("sh", "-c", command)
+	}
+	var out bytes.Buffer
+	c.Stdin = os.Stdin
+	c.Stdout = &out
+	c.Stderr = os.Stderr
+	if err := c.Run(); err != nil {
+		return "", fmt.Errorf("token command %q: %w", command, err)
+	}
+	line, _, _ := strings.Cut(out.String(), "\n")
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return "", fmt.Errorf("token command %q printed nothing", command)
+	}
+	return line, nil
+}
@@ -0,0 +1,94 @@
+package secret
+
+import (
+	"strings"
+	"testing"
+)
+
+// mapStore is a Store backed by a map.
+type mapStore map[string]string
+
+func (mapStore) Name() string { return "the test store" }
+
+func (s mapStore) Get(key string) (string, error) {
+	v, ok := s[key]
+	if !ok {
+		return "", ErrNotFound
+	}
+	return v, nil
+}
+
+func (s mapStore) Set(key, value string) error {
+	s[key] = value
+	return nil
+}
+
+func (s mapStore) Delete(key string) error {
+	if _, ok := s[key]; !ok {
+		return ErrNotFound
+	}
+	delete(s, key)
+	return nil
+}
+
+func TestResolve(t *testing.T) {
+	t.Setenv("REMOTE_AI_IDE_TEST_TOKEN", "from-env")
+	t.Setenv("REMOTE_AI_IDE_TEST_UNSET", "")
+	store := mapStore{"work": "from-store"}
+	open := func() Store { return store }
+
+	tests := []struct {
+		name  string
+		token string
+		want  string
+		err   string // expected substring of the error
+	}{
+		{"literal", "abc123", "abc123", ""},
+		{"literal with a colon", "bearer:abc", "bearer:abc", ""},
+		{"env", "env:REMOTE_AI_IDE_TEST_TOKEN", "from-env", ""},
+		{"unset env", "env:REMOTE_AI_IDE_TEST_UNSET", "", "REMOTE_AI_IDE_TEST_UNSET is not set"},
+		{"cmd", "cmd:echo from-cmd", "from-cmd", ""},
+		{"cmd with spaces around", "cmd:  echo from-cmd  ", "from-cmd", ""},
+		{"cmd failing", "cmd:exit 3", "", "token command"},
+		{"cmd printing nothing", "cmd:exit 0", "", "printed nothing"},
+		{"empty cmd", "cmd:", "", "empty token command"},
+		{"secret", "secret:work", "from-store", ""},
+		{"missing secret", "secret:home", "", `no secret "home" in the test store`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Resolve(tt.token, open)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("Resolve(%q) error = %v, want one containing %q", tt.token, err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Resolve(%q): %v", tt.token, err)
+			}
+			if got != tt.want {
+				t.Errorf("Resolve(%q) = %q, want %q", tt.token, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsReference(t *testing.T) {
+	tests := []struct {
+		token string
+		want  bool
+	}{
+		{"env:TOKEN", true},
+		{"cmd:pass show x", true},
+		{"secret:work", true},
+		{"abc123", false},
+		{"ENV:TOKEN", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := IsReference(tt.token); got != tt.want {
+			t.Errorf("IsReference(%q) = %v, want %v", tt.token, got, tt.want)
+		}
+	}
+}
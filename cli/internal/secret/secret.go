@@ -0,0 +1,72 @@
+// Package secret keeps server tokens out of the config file: in the OS
+// keyring when one is available, otherwise in an age-encrypted file.
+package secret
+
+import (
+	"errors"
+	"os"
+
+	"github.com/zalando/go-keyring"
+)
+
+// ErrNotFound is returned by Get when no secret is stored under the key.
+var ErrNotFound = errors.New("secret not found")
+
+// BackendEnv forces a backend ("keyring" or "file") instead of probing.
+const BackendEnv = "REMOTE_AI_IDE_SECRET_BACKEND"
+
+const service = "remote-ai-ide"
+
+// Store is a backend that holds secrets by key.
+type Store interface {
+	// Name describes where the secrets live, for messages.
+	Name() string
+	Get(key string) (string, error)
+	Set(key, value string) error
+	Delete(key string) error
+}
+
+// Open returns the OS keyring if it answers, or the encrypted file at path.
+func Open(path string) Store {
+	switch os.Getenv(BackendEnv) {
+	case "keyring":
+		return keyringStore{}
+	case "file":
+		return &fileStore{path: path}
+	}
+	if keyringAvailable() {
+		return keyringStore{}
+	}
+	return &fileStore{path: path}
+}
+
+// keyringAvailable probes the keyring; a missing entry still means a
+// Secret Service, Keychain or Credential Manager is there to ask.
+func keyringAvailable() bool {
+	_, err := keyring.Get(service, "availability-probe")
+	return err == nil || errors.Is(err, keyring.ErrNotFound)
+}
+
+type keyringStore struct{}
+
+func (keyringStore) Name() string { return "the system keyring" }
+
+func (keyringStore) Get(key string) (string, error) {
+	v, err := keyring.Get(service, key)
+	if errors.Is(err, keyring.ErrNotFound) {
+		return "", ErrNotFound
+	}
+	return v, err
+}
+
+func (keyringStore) Set(key, value string) error {
+	return keyring.Set(service, key, value)
+}
+
+func (keyringStore) Delete(key string) error {
+	err := keyring.Delete(service, key)
+	if errors.Is(err, keyring.ErrNotFound) {
+		return ErrNotFound
+	}
+	return err
+}
@@ -0,0 +1,236 @@
+package transcript
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/arvid/remote-ai-ide/cli/internal/client"
+)
+
+// Info describes a transcript file on disk.
+type Info struct {
+	Server    string
+	SessionID string
+	Path      string
+	Modified  time.Time
+}
+
+// List returns the transcripts of a server, or of all servers when server
+// is empty, most recently modified first.
+func List(root, server string) ([]Info, error) {
+	servers := []string{url.PathEscape(server)}
+	if server == "" {
+		dirs, err := os.ReadDir(root)
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		servers = servers[:0]
+		for _, d := range dirs {
+			if d.IsDir() {
+				servers = append(servers, d.Name())
+			}
+		}
+	}
+
+	var infos []Info
+	for _, dir := range servers {
+		files, err := os.ReadDir(filepath.Join(root, dir))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		name, _ := url.PathUnescape(dir)
+		for _, f := range files {
+			id, ok := strings.CutSuffix(f.Name(), ".jsonl")
+			if !ok || f.IsDir() {
+				continue
+			}
+			fi, err := f.Info()
+			if err != nil {
+				continue
+			}
+			id, _ = url.PathUnescape(id)
+			infos = append(infos, Info{
+				Server:    name,
+				SessionID: id,
+				Path:      filepath.Join(root, dir, f.Name()),
+				Modified:  fi.ModTime(),
+			})
+		}
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Modified.After(infos[j].Modified)
+	})
+	return infos, nil
+}
+
+// Find returns the transcript whose session ID starts with prefix.
+func Find(root, server, prefix string) (Info, error) {
+	infos, err := List(root, server)
+	if err != nil {
+		return Info{}, err
+	}
+	var found []Info
+	for _, info := range infos {
+		if info.SessionID == prefix {
+			return info, nil
+		}
+		if strings.HasPrefix(info.SessionID, prefix) {
+			found = append(found, info)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return Info{}, fmt.Errorf("no transcript for session %s", prefix)
+	case 1:
+		return found[0], nil
+	default:
+		return Info{}, fmt.Errorf("session prefix %s is ambiguous (%d transcripts)", prefix, len(found))
+	}
+}
+
+// Read parses a transcript file. A line cut short by a crash is skipped.
+func Read(path string) ([]Entry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []Entry
+	r := bufio.NewReader(f)
+	for {
+		line, err := r.ReadBytes('\n')
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			var e Entry
+			if json.Unmarshal(line, &e) == nil {
+				entries = append(entries, e)
+			}
+		}
+		if err == io.EOF {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// Message is a conversation event rebuilt from transcript entries. Role is
+// user, assistant, tool, permission or error. For permission messages
+// Content is "allowed", "denied" or empty while unanswered.
+//
+// Seq is the server sequence number of the frame, so messages can be
+// ordered against server history. The server numbers a user message one
+// past the last frame but never says so; other frames without a number get
+// the last one seen before them.
+type Message struct {
+	Time      time.Time
+	Seq       int
+	Role      string
+	Content   string
+	ToolName  string
+	ToolInput json.RawMessage
+}
+
+// Messages reduces raw frames to the conversation. Streaming chunks are
+// dropped in favour of the final assistant message, and a user message
+// sent again with its client seq, before the server answered the first
+// copy with anything numbered, is kept once.
+func Messages(entries []Entry) []Message {
+	var msgs []Message
+	pending := make(map[string]int) // permission request ID -> index in msgs
+	seq := 0
+	var last client.UserMessage // the last user message, until answered
+	for _, e := range entries {
+		var base client.ServerMessage
+		if json.Unmarshal(e.Frame, &base) != nil {
+			continue
+		}
+
+		if e.Dir == Out {
+			switch base.Type {
+			case "user_message":
+				var m client.UserMessage
+				if json.Unmarshal(e.Frame, &m) != nil {
+					continue
+				}
+				if m.Seq != 0 && m.Seq == last.Seq && m.Text == last.Text {
+					continue
+				}
+				last = m
+				seq++
+				msgs = append(msgs, Message{Time: e.Time, Seq: seq, Role: "user", Content: m.Text})
+			case "permission_response":
+				var m client.PermissionResponseMsg
+				if json.Unmarshal(e.Frame, &m) != nil {
+					continue
+				}
+				if i, ok := pending[m.RequestID]; ok {
+					msgs[i].Content = "denied"
+					if m.Allowed {
+						msgs[i].Content = "allowed"
+					}
+					delete(pending, m.RequestID)
+				}
+			}
+			continue
+		}
+
+		parsed, err := client.ParseServerMessage(e.Frame)
+		if err != nil {
+			continue
+		}
+		if frameSeq(parsed) != 0 {
+			last = client.UserMessage{}
+		}
+		switch m := parsed.(type) {
+		case *client.AssistantChunk:
+			seq = max(seq, m.Seq)
+		case *client.AssistantMessageMsg:
+			seq = max(seq, m.Seq)
+			msgs = append(msgs, Message{Time: e.Time, Seq: m.Seq, Role: "assistant", Content: m.Content})
+		case *client.ToolEvent:
+			seq = max(seq, m.Seq)
+			msgs = append(msgs, Message{Time: e.Time, Seq: m.Seq, Role: "tool", ToolName: m.ToolName, ToolInput: m.ToolInput})
+		case *client.PermissionRequest:
+			pending[m.RequestID] = len(msgs)
+			msgs = append(msgs, Message{Time: e.Time, Seq: seq, Role: "permission", ToolName: m.ToolName, ToolInput: m.ToolInput})
+		case *client.ResultMessage:
+			if !m.Success {
+				msgs = append(msgs, Message{Time: e.Time, Seq: seq, Role: "error", Content: m.Error})
+			}
+		}
+	}
+	return msgs
+}
+
+// frameSeq returns the server seq of a frame, or 0 if it has none.
+func frameSeq(ev client.Event) int {
+	switch m := ev.(type) {
+	case *client.AssistantChunk:
+		return m.Seq
+	case *client.AssistantMessageMsg:
+		return m.Seq
+	case *client.ToolEvent:
+		return m.Seq
+	case *client.ResultMessage:
+		return m.Seq
+	}
+	return 0
+}
@@ -0,0 +1,74 @@
+package transcript
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func entry(dir, frame string) Entry {
+	return Entry{Time: time.Now(), Dir: dir, Frame: json.RawMessage(frame)}
+}
+
+func TestMessagesUserResent(t *testing.T) {
+	rejected := `{"type":"result","sessionId":"","success":false,"error":"Rate limit exceeded","seq":0}`
+	tests := []struct {
+		name    string
+		entries []Entry
+		want    []string // the user messages kept
+	}{
+		{
+			name: "resent after a rejection",
+			entries: []Entry{
+				entry(Out, `{"type":"user_message","sessionId":"s","text":"hi","seq":1}`),
+				entry(In, rejected),
+				entry(Out, `{"type":"user_message","sessionId":"s","text":"hi","seq":1}`),
+				entry(In, `{"type":"assistant_message","sessionId":"s","content":"hello","seq":2}`),
+			},
+			want: []string{"hi"},
+		},
+		{
+			name: "same text, new number",
+			entries: []Entry{
+				entry(Out, `{"type":"user_message","sessionId":"s","text":"hi","seq":1}`),
+				entry(Out, `{"type":"user_message","sessionId":"s","text":"hi","seq":2}`),
+			},
+			want: []string{"hi", "hi"},
+		},
+		{
+			name: "numbering restarted by a new connection after an answer",
+			entries: []Entry{
+				entry(Out, `{"type":"user_message","sessionId":"s","text":"go on","seq":1}`),
+				entry(In, `{"type":"assistant_message","sessionId":"s","content":"ok","seq":2}`),
+				entry(Out, `{"type":"user_message","sessionId":"s","text":"go on","seq":1}`),
+			},
+			want: []string{"go on", "go on"},
+		},
+		{
+			name: "no client seq",
+			entries: []Entry{
+				entry(Out, `{"type":"user_message","sessionId":"s","text":"hi"}`),
+				entry(Out, `{"type":"user_message","sessionId":"s","text":"hi"}`),
+			},
+			want: []string{"hi", "hi"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, m := range Messages(tt.entries) {
+				if m.Role == "user" {
+					got = append(got, m.Content)
+				}
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("user messages %q, want %q", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("user messages %q, want %q", got, tt.want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,170 @@
+// Package transcript keeps a local JSONL log of every frame exchanged with a
+// session, so conversations outlive server restarts and session timeouts.
+package transcript
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	Out = "out" // sent by the client
+	In  = "in"  // received from the server
+)
+
+// Entry is one line of a transcript file.
+type Entry struct {
+	Time  time.Time       `json:"ts"`
+	Dir   string          `json:"dir"`
+	Frame json.RawMessage `json:"frame"`
+}
+
+// DefaultDir returns $XDG_DATA_HOME/remote-ai-ide, falling back to
+// ~/.local/share/remote-ai-ide.
+func DefaultDir() string {
+	if dir := os.Getenv("XDG_DATA_HOME"); dir != "" {
+		return filepath.Join(dir, "remote-ai-ide")
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(".local", "share", "remote-ai-ide")
+	}
+	return filepath.Join(home, ".local", "share", "remote-ai-ide")
+}
+
+// Path returns the transcript file of a session.
+func Path(root, server, sessionID string) string {
+	return filepath.Join(root, url.PathEscape(server), url.PathEscape(sessionID)+".jsonl")
+}
+
+// Store appends the traffic of one server connection to per-session files.
+// Its Record method fits client.Recorder.
+type Store struct {
+	root   string
+	server string
+
+	mu     sync.Mutex
+	files  map[string]*os.File
+	last   string // session of the last frame sent
+	err    error
+	closed bool
+}
+
+func Open(root, server string) *Store {
+	return &Store{root: root, server: server, files: make(map[string]*os.File)}
+}
+
+// Record appends a frame to the transcript of the session it names. A frame
+// from the server without a session, such as a rate-limit error, answers
+// the last frame sent and goes to that one's session; one before anything
+// was sent is dropped. Write errors are kept for Err rather than
+// interrupting the conversation.
+func (s *Store) Record(out bool, data []byte) {
+	var frame struct {
+		SessionID string `json:"sessionId"`
+	}
+	_ = json.Unmarshal(data, &frame)
+
+	e := Entry{Time: time.Now(), Dir: In, Frame: compact(data)}
+	if out {
+		e.Dir = Out
+	}
+	line, err := json.Marshal(e)
+	if err != nil {
+		s.fail(err)
+		return
+	}
+	line = append(line, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	switch {
+	case frame.SessionID != "" && out:
+		s.last = frame.SessionID
+	case frame.SessionID == "" && !out:
+		frame.SessionID = s.last
+	}
+	if frame.SessionID == "" {
+		return
+	}
+	f, err := s.file(frame.SessionID)
+	if err != nil {
+		s.setErr(err)
+		return
+	}
+	s.write(f, line)
+}
+
+// Err returns the first error hit while writing transcripts.
+func (s *Store) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	for id, f := range s.files {
+		if err := f.Close(); err != nil {
+			s.setErr(err)
+		}
+		delete(s.files, id)
+	}
+	return s.err
+}
+
+func (s *Store) file(sessionID string) (*os.File, error) {
+	if f, ok := s.files[sessionID]; ok {
+		return f, nil
+	}
+	path := Path(s.root, s.server, sessionID)
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("transcript: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("transcript: %w", err)
+	}
+	s.files[sessionID] = f
+	return f, nil
+}
+
+func (s *Store) write(f *os.File, line []byte) {
+	if _, err := f.Write(line); err != nil {
+		s.setErr(fmt.Errorf("transcript: %w", err))
+	}
+}
+
+func (s *Store) fail(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.setErr(fmt.Errorf("transcript: %w", err))
+}
+
+func (s *Store) setErr(err error) {
+	if s.err == nil {
+		s.err = err
+	}
+}
+
+// compact keeps each frame on one line. Anything that is not JSON is stored
+// as a string so the file stays parseable.
+func compact(data []byte) json.RawMessage {
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, data); err == nil {
+		return buf.Bytes()
+	}
+	s, _ := json.Marshal(string(data))
+	return s
+}
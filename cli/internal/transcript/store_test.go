@@ -0,0 +1,42 @@
+package transcript
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestRecordSessionless(t *testing.T) {
+	root := t.TempDir()
+	s := Open(root, "srv")
+	rejected := []byte(`{"type":"result","sessionId":"","success":false,"error":"Rate limit exceeded","seq":0}`)
+	s.Record(false, rejected) // before anything was sent
+	s.Record(true, []byte(`{"type":"user_message","sessionId":"a","text":"hi"}`))
+	s.Record(true, []byte(`{"type":"user_message","sessionId":"b","text":"hi"}`))
+	s.Record(false, rejected)
+	s.Record(true, []byte(`{"type":"ping"}`))
+	s.Record(false, rejected)
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		session string
+		want    int // entries
+	}{
+		{"a", 1},
+		{"b", 3},
+	}
+	for _, tt := range tests {
+		entries, err := Read(Path(root, "srv", tt.session))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != tt.want {
+			t.Errorf("transcript %s has %d entries, want %d", tt.session, len(entries), tt.want)
+		}
+	}
+	if _, err := os.Stat(Path(root, "srv", "")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("frames without a session got a transcript of their own: %v", err)
+	}
+}
@@ -1,38 +1,218 @@
 package tui
 
 import (
-	"fmt"
+	"bytes"
+	"encoding/json"
 	"strings"
+
+	"github.com/charmbracelet/lipgloss"
 )
 
 type chatMessage struct {
 	Role    string
 	Content string
+	Seq     int  // server seq; 0 for local entries not yet confirmed
+	Pending int  // outbox ID until a user message has been written
+	Sending bool // Pending was handed to the sender
+
+	// Set for Role "tool"
+	ToolName  string
+	ToolInput json.RawMessage
+	Expanded  bool // show the full input
+}
+
+// chatView renders a tab's chat and keeps the result entry by entry, so
+// an update that changes nothing shown, such as a cursor blink, costs a
+// comparison per entry rather than a render of the whole history.
+type chatView struct {
+	width   int
+	md      *markdownRenderer
+	entries []renderedEntry
+	stream  string // streamBuf as last rendered
+	streamR string
+
+	content string
+	starts  []int // content line of each entry
+
+	// content with the search matches highlighted
+	query   string
+	current int
+	lit     string
+	matches []int
+}
+
+type renderedEntry struct {
+	msg      chatMessage
+	selected bool
+	text     string
+	lines    int
+}
+
+// render returns the chat of t wrapped to width, with the lines matching
+// query highlighted and current the selected match, along with the
+// content line of each entry and of each match. Assistant output is shown
+// as markdown when md is non-nil and as raw text otherwise.
+func (v *chatView) render(t *tab, width int, md *markdownRenderer, query string, current int) (string, []int, []int) {
+	if width != v.width || md != v.md {
+		v.width, v.md = width, md
+		v.entries = nil
+		v.stream, v.streamR = "", ""
+	}
+
+	changed := len(v.entries) != len(t.messages)
+	if len(v.entries) > len(t.messages) {
+		v.entries = v.entries[:len(t.messages)]
+	}
+	for i, m := range t.messages {
+		selected := i == t.selected
+		if i < len(v.entries) && v.entries[i].selected == selected && sameEntry(v.entries[i].msg, m) {
+			continue
+		}
+		text := renderEntry(m, selected, width, md)
+		e := renderedEntry{msg: m, selected: selected, text: text, lines: strings.Count(text, "\n")}
+		if i < len(v.entries) {
+			v.entries[i] = e
+		} else {
+			v.entries = append(v.entries, e)
+		}
+		changed = true
+	}
+	if t.streamBuf != v.stream {
+		v.stream = t.streamBuf
+		v.streamR = renderStream(t.streamBuf, width, md)
+		changed = true
+	}
+
+	if changed {
+		var b strings.Builder
+		v.starts = make([]int, len(v.entries))
+		lines := 0
+		for i, e := range v.entries {
+			v.starts[i] = lines
+			lines += e.lines
+			b.WriteString(e.text)
+		}
+		b.WriteString(v.streamR)
+		v.content = strings.TrimRight(b.String(), "\n")
+	}
+	if changed || query != v.query || current != v.current {
+		v.query, v.current = query, current
+		v.lit, v.matches = highlightMatches(v.content, query, current)
+	}
+	return v.lit, v.starts, v.matches
 }
 
-func renderChat(messages []chatMessage, streamBuf string, width int) string {
-	var b strings.Builder
+// sameEntry reports whether a and b render the same.
+func sameEntry(a, b chatMessage) bool {
+	return a.Role == b.Role && a.Content == b.Content &&
+		a.Pending == b.Pending && a.Sending == b.Sending &&
+		a.ToolName == b.ToolName && a.Expanded == b.Expanded && bytes.Equal(a.ToolInput, b.ToolInput)
+}
 
-	for _, m := range messages {
-		switch m.Role {
-		case "user":
-			b.WriteString(userStyle.Render("You") + "\n")
-			b.WriteString(m.Content + "\n\n")
-		case "assistant":
-			b.WriteString(assistantStyle.Render("Claude") + "\n")
-			b.WriteString(m.Content + "\n\n")
-		case "error":
-			b.WriteString(errorStyle.Render("Error: "+m.Content) + "\n\n")
+// renderEntry renders one chat entry wrapped to width. selected marks the
+// tool call picked in history mode.
+func renderEntry(m chatMessage, selected bool, width int, md *markdownRenderer) string {
+	var block string
+	switch m.Role {
+	case "user":
+		head := userStyle.Render("You")
+		if m.Pending != 0 && !m.Sending {
+			head += " " + pendingStyle.Render("(pending)")
 		}
+		block = head + "\n" + m.Content + "\n\n"
+	case "assistant":
+		if md != nil {
+			block = assistantStyle.Render("Claude") + "\n" + md.render(m.Content, width) + "\n\n"
+		} else {
+			block = assistantStyle.Render("Claude") + "\n" + m.Content + "\n\n"
+		}
+	case "info":
+		block = m.Content + "\n\n"
+	case "tool":
+		block = renderToolCall(m, selected) + "\n\n"
+	case "error":
+		block = errorStyle.Render("Error: "+m.Content) + "\n\n"
+	default:
+		return ""
+	}
+	return wrapBlock(block, width)
+}
+
+// renderStream renders the reply being streamed, if any, with a cursor.
+func renderStream(streamBuf string, width int, md *markdownRenderer) string {
+	if streamBuf == "" {
+		return ""
+	}
+	block := assistantStyle.Render("Claude") + "\n"
+	if md != nil {
+		block += md.renderStream(streamBuf, width) + "\n"
+	} else {
+		block += streamStyle.Render(streamBuf)
+	}
+	return wrapBlock(block+streamStyle.Render("▊")+"\n", width)
+}
+
+// wrapBlock wraps a chat entry to width. The blank lines that end it are
+// kept out of the wrapping, which would pad them into the next entry.
+func wrapBlock(block string, width int) string {
+	block = strings.ReplaceAll(block, "\t", "    ")
+	if width <= 0 {
+		return block
+	}
+	body := strings.TrimRight(block, "\n")
+	return lipgloss.NewStyle().Width(width).Render(body) + block[len(body):]
+}
+
+func renderToolCall(m chatMessage, selected bool) string {
+	marker := "▸"
+	if m.Expanded {
+		marker = "▾"
 	}
+	var line string
+	if selected {
+		line = toolSelectedStyle.Render(marker + " " + m.ToolName + " " + summarizeToolInput(m.ToolInput))
+	} else {
+		line = toolStyle.Render(marker+" "+m.ToolName) + " " + toolSummaryStyle.Render(summarizeToolInput(m.ToolInput))
+	}
+	if !m.Expanded {
+		return line
+	}
+	return line + "\n" + toolInputStyle.Render(indentToolInput(m.ToolInput))
+}
 
-	if streamBuf != "" {
-		b.WriteString(assistantStyle.Render("Claude") + "\n")
-		b.WriteString(streamStyle.Render(streamBuf))
-		b.WriteString(streamStyle.Render("▊") + "\n")
+// summaryKeys are the tool input fields that best describe a call, in order of preference.
+var summaryKeys = []string{"command", "file_path", "notebook_path", "path", "pattern", "url", "query", "description"}
+
+// summarizeToolInput picks the most telling field of a tool input, such as
+// the file path of a Read or the command of a Bash call.
+func summarizeToolInput(raw json.RawMessage) string {
+	var fields map[string]interface{}
+	if err := json.Unmarshal(raw, &fields); err != nil || len(fields) == 0 {
+		return ""
 	}
+	for _, k := range summaryKeys {
+		v, ok := fields[k].(string)
+		if !ok || v == "" {
+			continue
+		}
+		if i := strings.IndexByte(v, '\n'); i >= 0 {
+			v = v[:i] + " …"
+		}
+		return truncate(v, 80)
+	}
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, raw); err != nil {
+		return ""
+	}
+	return truncate(compact.String(), 80)
+}
 
-	return b.String()
+func indentToolInput(raw json.RawMessage) string {
+	var out bytes.Buffer
+	if err := json.Indent(&out, raw, "  ", "  "); err != nil {
+		return "  " + string(raw)
+	}
+	return "  " + out.String()
 }
 
 func formatToolInput(raw []byte) string {
@@ -49,6 +229,3 @@ func truncate(s string, max int) string {
 	}
 	return s[:max] + "..."
 }
-
-// Unused but reserved for glamour phase 2
-var _ = fmt.Sprintf
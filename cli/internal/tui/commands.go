@@ -1,6 +1,13 @@
 package tui
 
-import "strings"
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/arvid/remote-ai-ide/cli/internal/export"
+)
 
 type slashCmd int
 
@@ -9,19 +16,40 @@ const (
 	cmdQuit
 	cmdReset
 	cmdHelp
+	cmdExport
+	cmdNew
+	cmdClose
+	cmdOutbox
+	cmdEdit
+	cmdDiscard
 )
 
-func parseSlashCommand(input string) slashCmd {
+// parseSlashCommand recognizes a slash command and returns its arguments.
+// Commands without arguments must be the whole input, so that a message
+// like "/help me with this" is still sent.
+func parseSlashCommand(input string) (slashCmd, string) {
 	input = strings.TrimSpace(input)
 	switch {
 	case input == "/quit" || input == "/exit":
-		return cmdQuit
+		return cmdQuit, ""
 	case input == "/reset":
-		return cmdReset
+		return cmdReset, ""
 	case input == "/help":
-		return cmdHelp
+		return cmdHelp, ""
+	case input == "/export" || strings.HasPrefix(input, "/export "):
+		return cmdExport, strings.TrimSpace(strings.TrimPrefix(input, "/export"))
+	case input == "/new" || strings.HasPrefix(input, "/new "):
+		return cmdNew, strings.TrimSpace(strings.TrimPrefix(input, "/new"))
+	case input == "/close":
+		return cmdClose, ""
+	case input == "/outbox":
+		return cmdOutbox, ""
+	case input == "/edit" || strings.HasPrefix(input, "/edit "):
+		return cmdEdit, strings.TrimSpace(strings.TrimPrefix(input, "/edit"))
+	case input == "/discard" || strings.HasPrefix(input, "/discard "):
+		return cmdDiscard, strings.TrimSpace(strings.TrimPrefix(input, "/discard"))
 	default:
-		return cmdNone
+		return cmdNone, ""
 	}
 }
 
@@ -29,9 +57,43 @@ func helpText() string {
 	return `Available commands:
   /help   - Show this help
   /reset  - Reset the current session
+  /export [md|html|json] [file] - Export the session (default: Markdown in the current directory)
+  /new [path] - Open a session in a new tab (default: this tab's project)
+  /close  - Close this tab; the session stays on the server
+  /outbox - List messages waiting to be sent
+  /edit [n] - Load pending message n (default: the last) into the composer; Enter saves it
+  /discard [n] - Drop pending message n (default: the last)
   /quit   - Exit the application
 
 Shortcuts:
+  Alt+Enter - New line (also Ctrl+J; map Shift+Enter to Alt+Enter in your terminal)
+  Ctrl+E  - Compose the message in $EDITOR
   Ctrl+C  - Interrupt current operation
+  Ctrl+T  - New tab in the same project
+  Ctrl+Tab, Ctrl+Shift+Tab - Next/previous tab, where the terminal reports them
+  Alt+←/→ - Previous/next tab in any terminal (also Ctrl+PgUp/PgDn, Alt+1..9 to jump)
+  Ctrl+O  - Expand/collapse the input of the latest tool call
+  Ctrl+R  - Toggle markdown rendering of replies
+  PgUp/PgDn - Scroll the conversation (mouse wheel works too)
+  Esc     - Browse history (j/k scroll, / search, n/N next/prev match, t/T pick a tool call, o expand it)
+  Ctrl+F  - Search the conversation
   Ctrl+D  - Quit`
 }
+
+// parseExportArgs splits "/export" arguments into a format and an optional
+// file name. Without an explicit format, the file extension picks one.
+func parseExportArgs(args string) (format, path string, err error) {
+	first, rest, _ := strings.Cut(args, " ")
+	if slices.Contains(export.Formats, first) {
+		return first, strings.TrimSpace(rest), nil
+	}
+	path = args
+	format = strings.TrimPrefix(filepath.Ext(path), ".")
+	switch {
+	case format == "" || format == "markdown":
+		format = "md"
+	case !slices.Contains(export.Formats, format):
+		return "", "", fmt.Errorf("usage: /export [md|html|json] [file]")
+	}
+	return format, path, nil
+}
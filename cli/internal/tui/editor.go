@@ -0,0 +1,65 @@
+package tui
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// editorFinishedMsg reports that the external editor exited. The file is
+// removed once its contents have been read.
+type editorFinishedMsg struct {
+	path string
+	err  error
+}
+
+// openEditor suspends the TUI and edits content in $VISUAL or $EDITOR,
+// falling back to vi.
+func openEditor(content string) tea.Cmd {
+	f, err := os.CreateTemp("", "remote-ai-ide-*.md")
+	if err != nil {
+		return func() tea.Msg { return editorFinishedMsg{err: err} }
+	}
+	_, err = f.WriteString(content)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return func() tea.Msg { return editorFinishedMsg{err: err} }
+	}
+
+	// The variable may carry arguments, as in EDITOR="code --wait"
+	args := strings.Fields(editorCommand())
+	c := exec.Command(args[0], append(args[1:], f.Name())...)
+	return tea.ExecProcess(c, func(err error) tea.Msg {
+		return editorFinishedMsg{path: f.Name(), err: err}
+	})
+}
+
+func editorCommand() string {
+	for _, env := range []string{"VISUAL", "EDITOR"} {
+		if e := strings.TrimSpace(os.Getenv(env)); e != "" {
+			return e
+		}
+	}
+	return "vi"
+}
+
+// readEditorFile returns the saved message and removes the temp file.
+func readEditorFile(msg editorFinishedMsg) (string, error) {
+	if msg.path != "" {
+		defer os.Remove(msg.path)
+	}
+	if msg.err != nil {
+		return "", fmt.Errorf("editor: %w", msg.err)
+	}
+	data, err := os.ReadFile(msg.path)
+	if err != nil {
+		return "", fmt.Errorf("editor: %w", err)
+	}
+	return string(data), nil
+}
@@ -0,0 +1,138 @@
+package tui
+
+import (
+	"os"
+	"strings"
+
+	"github.com/charmbracelet/glamour"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// markdownRenderer renders assistant output with glamour. Each chat view
+// keeps the rendering of its entries, so only the last message and the
+// last state of the stream buffer are kept here, for a view asking again.
+type markdownRenderer struct {
+	style string
+	width int
+	r     *glamour.TermRenderer
+
+	lastIn  string
+	lastOut string
+
+	// The stream buffer changes with every chunk, so only its latest
+	// rendering is kept.
+	streamIn  string
+	streamOut string
+}
+
+// newMarkdownRenderer picks the glamour style from GLAMOUR_STYLE or the
+// terminal background. It must run before bubbletea takes over the terminal,
+// since detecting the background queries it.
+func newMarkdownRenderer() *markdownRenderer {
+	style := os.Getenv("GLAMOUR_STYLE")
+	if style == "" {
+		style = "light"
+		if lipgloss.HasDarkBackground() {
+			style = "dark"
+		}
+	}
+	return &markdownRenderer{style: style}
+}
+
+// render returns content as styled markdown wrapped to width, falling back
+// to the raw text if glamour fails.
+func (md *markdownRenderer) render(content string, width int) string {
+	if !md.resize(width) {
+		return content
+	}
+	if content == md.lastIn && md.lastOut != "" {
+		return md.lastOut
+	}
+	out, err := md.r.Render(content)
+	if err != nil {
+		return content
+	}
+	md.lastIn, md.lastOut = content, strings.Trim(out, "\n")
+	return md.lastOut
+}
+
+// renderStream renders a partially received message. An unterminated code
+// fence is closed first so the rest of the message is not treated as code
+// until the closing fence actually arrives.
+func (md *markdownRenderer) renderStream(content string, width int) string {
+	if !md.resize(width) {
+		return content
+	}
+	if content == md.streamIn && md.streamOut != "" {
+		return md.streamOut
+	}
+	out, err := md.r.Render(closeOpenFence(content))
+	if err != nil {
+		return content
+	}
+	md.streamIn, md.streamOut = content, strings.Trim(out, "\n")
+	return md.streamOut
+}
+
+// resize rebuilds the renderer when the wrap width changes.
+func (md *markdownRenderer) resize(width int) bool {
+	if width <= 0 {
+		return false
+	}
+	if md.r != nil && md.width == width {
+		return true
+	}
+	r, err := glamour.NewTermRenderer(
+		glamour.WithStandardStyle(md.style),
+		glamour.WithWordWrap(width),
+	)
+	if err != nil {
+		return false
+	}
+	md.r, md.width = r, width
+	md.lastIn, md.lastOut = "", ""
+	md.streamIn, md.streamOut = "", ""
+	return true
+}
+
+// closeOpenFence appends a closing fence if content ends inside a fenced
+// code block. As in CommonMark, a block closes only on a bare fence of the
+// same marker at least as long as the one that opened it, so a longer
+// fence can hold a shorter one.
+func closeOpenFence(content string) string {
+	var fence string
+	for _, line := range strings.Split(content, "\n") {
+		trimmed := strings.TrimLeft(line, " ")
+		if len(line)-len(trimmed) > 3 {
+			continue
+		}
+		run := fenceRun(trimmed)
+		switch {
+		case run == "":
+		case fence == "":
+			fence = run
+		case run[0] == fence[0] && len(run) >= len(fence) && strings.TrimSpace(trimmed[len(run):]) == "":
+			fence = ""
+		}
+	}
+	if fence == "" {
+		return content
+	}
+	if !strings.HasSuffix(content, "\n") {
+		content += "\n"
+	}
+	return content + fence
+}
+
+// fenceRun returns the run of three or more backticks or tildes that line
+// starts with, or "" if it starts no fence.
+func fenceRun(line string) string {
+	if line == "" || (line[0] != '`' && line[0] != '~') {
+		return ""
+	}
+	n := len(line) - len(strings.TrimLeft(line, line[:1]))
+	if n < 3 {
+		return ""
+	}
+	return line[:n]
+}
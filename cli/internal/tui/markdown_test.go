@@ -0,0 +1,31 @@
+package tui
+
+import "testing"
+
+func TestCloseOpenFence(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"no fence", "plain text", "plain text"},
+		{"closed", "```go\nx := 1\n```", "```go\nx := 1\n```"},
+		{"unclosed", "```go\nx := 1", "```go\nx := 1\n```"},
+		{"unclosed at a newline", "```go\nx := 1\n", "```go\nx := 1\n```"},
+		{"unclosed tilde", "~~~\nx", "~~~\nx\n~~~"},
+		{"other marker inside", "```\n~~~\nx", "```\n~~~\nx\n```"},
+		{"info string does not close", "```\nx\n```go", "```\nx\n```go\n```"},
+		{"nested in a longer fence", "````md\n```go\nx\n```\n", "````md\n```go\nx\n```\n````"},
+		{"longer fence closes", "```\nx\n`````", "```\nx\n`````"},
+		{"second block unclosed", "```\na\n```\ntext\n```\nb", "```\na\n```\ntext\n```\nb\n```"},
+		{"indented code is not a fence", "    ```\nx", "    ```\nx"},
+		{"too short", "``\nx", "``\nx"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := closeOpenFence(tt.content); got != tt.want {
+				t.Errorf("closeOpenFence(%q) = %q, want %q", tt.content, got, tt.want)
+			}
+		})
+	}
+}
@@ -2,43 +2,136 @@ package tui
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/arvid/remote-ai-ide/cli/internal/client"
+	"github.com/arvid/remote-ai-ide/cli/internal/export"
+	"github.com/arvid/remote-ai-ide/cli/internal/outbox"
+	"github.com/arvid/remote-ai-ide/cli/internal/policy"
+	"github.com/arvid/remote-ai-ide/cli/internal/transcript"
+	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/textarea"
+	"github.com/charmbracelet/bubbles/textinput"
+	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
 )
 
 // Tea messages wrapping WS events
-type wsMsg struct{ data []byte }
+type wsEvent struct{ ev client.Event }
 type wsDisconnect struct{}
-type errMsg struct{ err error }
 
-func listenWS(ws *client.WSClient) tea.Cmd {
+// resyncMsg carries the history of a session missed while the connection
+// was down.
+type resyncMsg struct {
+	sessionID string
+	messages  []client.HistoryMessage
+	err       error
+}
+
+func listenWS(conn *client.Conn) tea.Cmd {
 	return func() tea.Msg {
-		select {
-		case data, ok := <-ws.Messages:
-			if !ok {
-				return wsDisconnect{}
-			}
-			return wsMsg{data: data}
-		case <-ws.Done:
+		ev, ok := <-conn.Events()
+		if !ok {
 			return wsDisconnect{}
 		}
+		return wsEvent{ev: ev}
 	}
 }
 
-type Model struct {
-	ws        *client.WSClient
-	sessionID string
-	server    string
+func resync(rest *client.RESTClient, sessionID string, since int) tea.Cmd {
+	return func() tea.Msg {
+		detail, err := rest.GetSession(sessionID, since)
+		if err != nil {
+			return resyncMsg{sessionID: sessionID, err: err}
+		}
+		return resyncMsg{sessionID: sessionID, messages: detail.Messages}
+	}
+}
 
-	messages  []chatMessage
-	streamBuf string
-	connected bool
-	status    string // ready, busy, error
+// exportMsg reports the outcome of /export.
+type exportMsg struct {
+	path     string
+	entries  int
+	fallback error
+	err      error
+}
 
-	permReq *client.PermissionRequest
+func exportSession(rest *client.RESTClient, server, sessionID, format, path string) tea.Cmd {
+	return func() tea.Msg {
+		s, fallback, err := export.Load(rest, transcript.DefaultDir(), server, sessionID, 0)
+		if err != nil {
+			return exportMsg{err: err}
+		}
+		if path == "" {
+			path = export.FileName(s, format)
+		}
+		f, err := os.Create(path)
+		if err != nil {
+			return exportMsg{err: err}
+		}
+		err = export.Write(f, s, format)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		return exportMsg{path: path, entries: len(s.Entries), fallback: fallback, err: err}
+	}
+}
+
+// Options configures a chat Model.
+type Options struct {
+	REST       *client.RESTClient
+	SessionID  string
+	Project    string
+	ServerName string
+	Paths      PathMapper              // maps tool paths for display; nil leaves them as is
+	History    []client.HistoryMessage // backfilled into the chat on start
+	Policy     *policy.Policy          // answers permission requests; nil always asks
+
+	// LoadPolicy builds the policy for sessions opened in new tabs.
+	LoadPolicy func(project string) (*policy.Policy, error)
+
+	// Outbox holds messages typed while disconnected; nil keeps them in
+	// memory only.
+	Outbox *outbox.Outbox
+
+	// Logger receives diagnostics that must not be written over the
+	// screen; nil discards them.
+	Logger *slog.Logger
+}
+
+type Model struct {
+	conn       *client.Conn
+	sender     *sender
+	outbox     *outbox.Outbox
+	rest       *client.RESTClient
+	server     string
+	paths      PathMapper
+	loadPolicy func(project string) (*policy.Policy, error)
+	log        *slog.Logger
+
+	tabs   []*tab
+	active int
+
+	connected    bool
+	reconnecting *client.Reconnecting
+	queued       int           // frames the client is holding back
+	rtt          time.Duration // round trip of the last ping
+
+	md        *markdownRenderer
+	rawOutput bool // show assistant output as plain text instead of markdown
+
+	viewport viewport.Model
+	content  string // what the viewport shows
+	starts   []int  // content line of each chat entry of the current tab
+	browsing bool   // keys scroll the history instead of editing the input
+	search   search
 
 	input    textarea.Model
 	width    int
@@ -46,29 +139,140 @@ type Model struct {
 	quitting bool
 }
 
-func NewModel(ws *client.WSClient, sessionID, serverName string) Model {
+// The composer grows with its content between these heights.
+const (
+	minInputHeight = 3
+	maxInputHeight = 12
+)
+
+func NewModel(conn *client.Conn, opts Options) Model {
 	ti := textarea.New()
 	ti.Placeholder = "Type a message..."
 	ti.Focus()
-	ti.SetHeight(3)
+	ti.SetHeight(minInputHeight)
 	ti.ShowLineNumbers = false
-	ti.KeyMap.InsertNewline.SetEnabled(false)
+	// Enter sends; Shift+Enter only arrives as alt+enter when the terminal
+	// is set up to send it that way.
+	ti.KeyMap.InsertNewline = key.NewBinding(key.WithKeys("alt+enter", "ctrl+j"))
 
-	return Model{
-		ws:        ws,
-		sessionID: sessionID,
-		server:    serverName,
-		connected: true,
-		status:    "ready",
-		input:     ti,
+	first := newTab(opts.SessionID, opts.Project, opts.Policy)
+	first.applyHistory(opts.History)
+
+	loadPolicy := opts.LoadPolicy
+	if loadPolicy == nil {
+		loadPolicy = func(string) (*policy.Policy, error) { return opts.Policy, nil }
+	}
+	paths := opts.Paths
+	if paths == nil {
+		paths = identityPaths{}
+	}
+	logger := opts.Logger
+	if logger == nil {
+		logger = slog.New(slog.DiscardHandler)
 	}
+	ob := opts.Outbox
+	if ob == nil {
+		ob = outbox.New()
+	}
+	m := Model{
+		conn:       conn,
+		sender:     newSender(conn, ob),
+		outbox:     ob,
+		rest:       opts.REST,
+		server:     opts.ServerName,
+		paths:      paths,
+		loadPolicy: loadPolicy,
+		log:        logger.With("component", "tui"),
+		tabs:       []*tab{first},
+		connected:  true,
+		viewport:   viewport.New(0, 0),
+		search:     newSearch(),
+		md:         newMarkdownRenderer(),
+		input:      ti,
+	}
+
+	// Messages left pending by an earlier run go out now
+	m.flush(first)
+	if n := ob.Others(first.sessionID); n > 0 {
+		first.messages = append(first.messages, chatMessage{
+			Role:    "info",
+			Content: fmt.Sprintf("%d message(s) are pending for other sessions; attach to them with connect --session to send them.", n),
+		})
+	}
+	return m
+}
+
+// cur returns the active tab.
+func (m Model) cur() *tab {
+	return m.tabs[m.active]
+}
+
+// findTab returns the tab of a session, or nil.
+func (m Model) findTab(sessionID string) *tab {
+	for _, t := range m.tabs {
+		if t.sessionID == sessionID {
+			return t
+		}
+	}
+	return nil
+}
+
+// switchTab makes tab i active, keeping the composer draft and scroll
+// position of the tab being left.
+func (m *Model) switchTab(i int) tea.Cmd {
+	if i < 0 || i >= len(m.tabs) || i == m.active {
+		return nil
+	}
+	old := m.cur()
+	old.draft = m.input.Value()
+	old.yOffset = m.viewport.YOffset
+	return m.activate(i)
+}
+
+// activate shows tab i, restoring its draft and scroll position.
+func (m *Model) activate(i int) tea.Cmd {
+	m.active = i
+	t := m.cur()
+	t.unread = false
+	m.search.clear()
+	m.browsing = false
+	m.input.SetValue(t.draft)
+	m.fitInput()
+	m.syncViewport()
+	if !t.follow {
+		m.viewport.SetYOffset(t.yOffset)
+	}
+	return m.input.Focus()
+}
+
+// closeTab drops the active tab. The session stays on the server.
+func (m Model) closeTab() (tea.Model, tea.Cmd) {
+	if len(m.tabs) == 1 {
+		m.quitting = true
+		return m, tea.Quit
+	}
+	closed := m.cur()
+	m.tabs = append(m.tabs[:m.active:m.active], m.tabs[m.active+1:]...)
+	cmd := m.activate(min(m.active, len(m.tabs)-1))
+	m.cur().messages = append(m.cur().messages, chatMessage{
+		Role:    "info",
+		Content: fmt.Sprintf("Closed session %s; it stays on the server until it times out.", closed.sessionID),
+	})
+	return m, cmd
 }
 
 func (m Model) Init() tea.Cmd {
-	return tea.Batch(textarea.Blink, listenWS(m.ws))
+	return tea.Batch(textarea.Blink, m.listen(), listenSender(m.sender))
 }
 
 func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	next, cmd := m.update(msg)
+	nm := next.(Model)
+	nm.syncViewport()
+	return nm, cmd
+}
+
+func (m Model) update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case tea.WindowSizeMsg:
 		m.width = msg.Width
@@ -76,34 +280,128 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.input.SetWidth(msg.Width - 4)
 		return m, nil
 
+	case tea.MouseMsg:
+		var cmd tea.Cmd
+		m.viewport, cmd = m.viewport.Update(msg)
+		m.cur().follow = m.viewport.AtBottom()
+		return m, cmd
+
 	case tea.KeyMsg:
-		if m.permReq != nil {
+		switch msg.Type {
+		case tea.KeyPgUp:
+			m.viewport.PageUp()
+			m.cur().follow = m.viewport.AtBottom()
+			return m, nil
+		case tea.KeyPgDown:
+			m.viewport.PageDown()
+			m.cur().follow = m.viewport.AtBottom()
+			return m, nil
+		}
+		if i, ok := m.tabKey(msg); ok {
+			return m, m.switchTab(i)
+		}
+		if m.search.typing {
+			return m.handleSearchKey(msg)
+		}
+		if m.cur().permReq != nil {
 			return m.handlePermissionKey(msg)
 		}
+		if m.browsing {
+			return m.handleBrowseKey(msg)
+		}
 		return m.handleKey(msg)
 
-	case wsMsg:
-		return m.handleWS(msg.data)
+	case wsEvent:
+		return m.handleEvent(msg.ev)
+
+	case resyncMsg:
+		t := m.findTab(msg.sessionID)
+		if t == nil {
+			return m, nil
+		}
+		t.syncing = false
+		if msg.err != nil {
+			m.log.Warn("resync failed", "session", msg.sessionID, "err", msg.err)
+			t.messages = append(t.messages, chatMessage{Role: "error", Content: "resync failed: " + msg.err.Error()})
+		} else {
+			t.applyHistory(msg.messages)
+		}
+		if m.connected {
+			m.flush(t)
+		}
+		return m, nil
+
+	case newTabMsg:
+		if msg.err != nil {
+			m.cur().messages = append(m.cur().messages, chatMessage{Role: "error", Content: "new tab: " + msg.err.Error()})
+			return m, nil
+		}
+		m.log.Info("opened tab", "session", msg.tab.sessionID, "project", msg.tab.project)
+		m.tabs = append(m.tabs, msg.tab)
+		return m, m.switchTab(len(m.tabs) - 1)
 
 	case wsDisconnect:
 		m.connected = false
+		m.reconnecting = nil
+		m.queued = 0
+		m.cur().messages = append(m.cur().messages, chatMessage{
+			Role:    "info",
+			Content: "Connection lost. Messages you send now are kept in the outbox and sent when you next connect to this session.",
+		})
 		return m, nil
 
-	case errMsg:
-		m.messages = append(m.messages, chatMessage{Role: "error", Content: msg.err.Error()})
-		return m, listenWS(m.ws)
+	case sendResultMsg:
+		if msg.pending != 0 {
+			m.settle(msg)
+		} else if !errors.Is(msg.err, client.ErrClosed) {
+			m.cur().messages = append(m.cur().messages, chatMessage{Role: "error", Content: msg.err.Error()})
+		}
+		return m, listenSender(m.sender)
+
+	case exportMsg:
+		if msg.err != nil {
+			m.cur().messages = append(m.cur().messages, chatMessage{Role: "error", Content: "export failed: " + msg.err.Error()})
+			return m, nil
+		}
+		note := fmt.Sprintf("Exported %d entries to %s", msg.entries, msg.path)
+		if msg.fallback != nil {
+			note += fmt.Sprintf(" (from the local transcript only: %v)", msg.fallback)
+		}
+		m.cur().messages = append(m.cur().messages, chatMessage{Role: "info", Content: note})
+		return m, nil
+
+	case editorFinishedMsg:
+		text, err := readEditorFile(msg)
+		if err != nil {
+			m.cur().messages = append(m.cur().messages, chatMessage{Role: "error", Content: err.Error()})
+			return m, textarea.Blink
+		}
+		if strings.TrimSpace(text) == "" {
+			// Keep the draft so quitting the editor without saving loses nothing
+			return m, textarea.Blink
+		}
+		m.input.Reset()
+		m.fitInput()
+		model, cmd := m.submit(text)
+		return model, tea.Batch(cmd, textarea.Blink)
+
+	default:
+		if i, ok := m.ctrlTab(msg); ok {
+			return m, m.switchTab(i)
+		}
 	}
 
 	var cmd tea.Cmd
 	m.input, cmd = m.input.Update(msg)
+	m.fitInput()
 	return m, cmd
 }
 
 func (m Model) handleKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	switch msg.Type {
 	case tea.KeyCtrlC:
-		if m.status == "busy" {
-			m.ws.Send(client.NewInterrupt(m.sessionID))
+		if m.cur().status == "busy" {
+			m.sender.send(client.NewInterrupt(m.cur().sessionID))
 			return m, nil
 		}
 		m.quitting = true
@@ -113,96 +411,486 @@ func (m Model) handleKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		m.quitting = true
 		return m, tea.Quit
 
+	case tea.KeyCtrlO:
+		t := m.cur()
+		if i := t.toolIndex(-1); i >= 0 {
+			t.messages[i].Expanded = !t.messages[i].Expanded
+		}
+		return m, nil
+
+	case tea.KeyCtrlR:
+		m.rawOutput = !m.rawOutput
+		return m, nil
+
+	case tea.KeyCtrlF:
+		m.input.Blur()
+		m.search.start()
+		return m, textinput.Blink
+
+	case tea.KeyEsc:
+		m.browsing = true
+		m.input.Blur()
+		return m, nil
+
+	case tea.KeyCtrlE:
+		return m, openEditor(m.input.Value())
+
+	case tea.KeyCtrlT:
+		return m, openTab(m.rest, m.loadPolicy, m.cur().project)
+
 	case tea.KeyEnter:
-		text := strings.TrimSpace(m.input.Value())
-		if text == "" {
+		if msg.Alt {
+			break
+		}
+		text := m.input.Value()
+		if strings.TrimSpace(text) == "" {
 			return m, nil
 		}
 		m.input.Reset()
+		m.fitInput()
+		return m.submit(text)
+	}
+
+	if msg.Paste {
+		// Terminals send pasted line breaks as CR; Windows text adds CRLF
+		text := strings.ReplaceAll(string(msg.Runes), "\r\n", "\n")
+		m.input.InsertString(strings.ReplaceAll(text, "\r", "\n"))
+		m.fitInput()
+		return m, nil
+	}
+
+	var teaCmd tea.Cmd
+	m.input, teaCmd = m.input.Update(msg)
+	m.fitInput()
+	return m, teaCmd
+}
+
+// submit handles a message from the composer or the external editor.
+func (m Model) submit(text string) (tea.Model, tea.Cmd) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return m, nil
+	}
+
+	t := m.cur()
 
-		// Check slash commands
-		cmd := parseSlashCommand(text)
-		switch cmd {
-		case cmdQuit:
-			m.quitting = true
-			return m, tea.Quit
-		case cmdReset:
-			m.ws.Send(client.NewResetSession(m.sessionID))
-			m.messages = append(m.messages, chatMessage{Role: "error", Content: "Session reset requested"})
+	// Check slash commands
+	cmd, args := parseSlashCommand(text)
+	switch cmd {
+	case cmdQuit:
+		m.quitting = true
+		return m, tea.Quit
+	case cmdReset:
+		m.sender.send(client.NewResetSession(t.sessionID))
+		t.messages = append(t.messages, chatMessage{Role: "error", Content: "Session reset requested"})
+		return m, nil
+	case cmdHelp:
+		t.messages = append(t.messages, chatMessage{Role: "info", Content: helpText()})
+		return m, nil
+	case cmdExport:
+		format, path, err := parseExportArgs(args)
+		if err != nil {
+			t.messages = append(t.messages, chatMessage{Role: "error", Content: err.Error()})
 			return m, nil
-		case cmdHelp:
-			m.messages = append(m.messages, chatMessage{Role: "assistant", Content: helpText()})
+		}
+		return m, exportSession(m.rest, m.server, t.sessionID, format, path)
+	case cmdNew:
+		project := t.project
+		if args != "" {
+			abs, err := filepath.Abs(args)
+			if err != nil {
+				t.messages = append(t.messages, chatMessage{Role: "error", Content: err.Error()})
+				return m, nil
+			}
+			project = m.paths.ToRemote(abs)
+		}
+		return m, openTab(m.rest, m.loadPolicy, project)
+	case cmdClose:
+		return m.closeTab()
+	case cmdOutbox:
+		t.messages = append(t.messages, chatMessage{Role: "info", Content: m.listOutbox(t)})
+		return m, nil
+	case cmdEdit:
+		p, err := m.pickPending(t, args)
+		if err != nil {
+			t.messages = append(t.messages, chatMessage{Role: "error", Content: err.Error()})
+			return m, nil
+		}
+		t.editing = p.ID
+		m.input.SetValue(p.Text)
+		m.fitInput()
+		return m, nil
+	case cmdDiscard:
+		p, err := m.pickPending(t, args)
+		if err != nil {
+			t.messages = append(t.messages, chatMessage{Role: "error", Content: err.Error()})
 			return m, nil
 		}
+		m.discard(t, p)
+		return m, nil
+	}
 
-		// Regular message
-		m.messages = append(m.messages, chatMessage{Role: "user", Content: text})
-		m.ws.Send(client.NewUserMessage(m.sessionID, text))
+	// Regular message
+	t.follow = true
+	if t.editing != 0 {
+		m.saveEdit(t, text)
 		return m, nil
 	}
+	m.post(t, text)
+	return m, nil
+}
 
-	var teaCmd tea.Cmd
-	m.input, teaCmd = m.input.Update(msg)
-	return m, teaCmd
+// tabKey maps tab navigation keys to the index of the tab to show. Most
+// terminals do not report Ctrl+Tab, so Alt+arrows and Ctrl+PgUp/PgDn cycle
+// too; see ctrlTab for those that do.
+func (m Model) tabKey(msg tea.KeyMsg) (int, bool) {
+	n := len(m.tabs)
+	switch msg.String() {
+	case "alt+right", "ctrl+pgdown":
+		return (m.active + 1) % n, n > 1
+	case "alt+left", "ctrl+pgup":
+		return (m.active + n - 1) % n, n > 1
+	case "alt+1", "alt+2", "alt+3", "alt+4", "alt+5", "alt+6", "alt+7", "alt+8", "alt+9":
+		i := int(msg.Runes[0] - '1')
+		return i, i < n
+	}
+	return 0, false
+}
+
+// Bubbletea has no key for Ctrl+Tab. Terminals that report it send one of
+// these CSI sequences, which reach Update as unknown-sequence messages known
+// only by their text, mapped here to the direction to cycle.
+var ctrlTabSeqs = map[string]int{
+	unknownCSI("9;5u"):    1,  // CSI u: Ctrl+Tab
+	unknownCSI("9;6u"):    -1, // CSI u: Ctrl+Shift+Tab
+	unknownCSI("27;5;9~"): 1,  // xterm modifyOtherKeys
+	unknownCSI("27;6;9~"): -1,
+}
+
+// unknownCSI is how bubbletea prints an unrecognised CSI sequence.
+func unknownCSI(params string) string {
+	return fmt.Sprintf("?CSI%+v?", []byte(params))
+}
+
+// ctrlTab maps Ctrl+Tab and Ctrl+Shift+Tab to the index of the tab to show.
+func (m Model) ctrlTab(msg tea.Msg) (int, bool) {
+	s, ok := msg.(fmt.Stringer)
+	if !ok {
+		return 0, false
+	}
+	step, ok := ctrlTabSeqs[s.String()]
+	n := len(m.tabs)
+	if !ok || n < 2 {
+		return 0, false
+	}
+	return (m.active + n + step) % n, true
+}
+
+// fitInput grows or shrinks the composer to its content.
+func (m *Model) fitInput() {
+	h := m.input.LineCount()
+	h = max(minInputHeight, min(h, maxInputHeight))
+	if h != m.input.Height() {
+		m.input.SetHeight(h)
+	}
+}
+
+// handleBrowseKey scrolls and searches the history while the input is blurred.
+func (m Model) handleBrowseKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "up", "k":
+		m.viewport.ScrollUp(1)
+	case "down", "j":
+		m.viewport.ScrollDown(1)
+	case "b":
+		m.viewport.PageUp()
+	case "f", " ":
+		m.viewport.PageDown()
+	case "g", "home":
+		m.viewport.GotoTop()
+	case "G", "end":
+		m.viewport.GotoBottom()
+	case "/", "ctrl+f":
+		m.search.start()
+		return m, textinput.Blink
+	case "n":
+		m.jumpToMatch(1)
+	case "N":
+		m.jumpToMatch(-1)
+	case "t":
+		m.selectTool(1)
+	case "T":
+		m.selectTool(-1)
+	case "o":
+		t := m.cur()
+		i := t.selected
+		if i < 0 {
+			i = t.toolIndex(-1)
+		}
+		if i >= 0 {
+			t.messages[i].Expanded = !t.messages[i].Expanded
+		}
+	case "esc":
+		if m.search.query != "" {
+			m.search.clear()
+			return m, nil
+		}
+		return m.stopBrowsing()
+	case "i", "enter", "ctrl+c":
+		return m.stopBrowsing()
+	case "ctrl+d":
+		m.quitting = true
+		return m, tea.Quit
+	}
+	m.cur().follow = m.viewport.AtBottom()
+	return m, nil
+}
+
+func (m Model) stopBrowsing() (tea.Model, tea.Cmd) {
+	m.browsing = false
+	m.cur().selected = -1
+	m.cur().follow = true
+	return m, m.input.Focus()
+}
+
+// handleSearchKey edits the search query, jumping to matches as it changes.
+func (m Model) handleSearchKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.Type {
+	case tea.KeyEnter:
+		m.search.typing = false
+		m.search.input.Blur()
+		if m.search.query == "" {
+			m.search.clear()
+		}
+		m.browsing = true
+		return m, nil
+	case tea.KeyEsc, tea.KeyCtrlC:
+		m.search.clear()
+		if !m.browsing {
+			return m, m.input.Focus()
+		}
+		return m, nil
+	}
+
+	var cmd tea.Cmd
+	m.search.input, cmd = m.search.input.Update(msg)
+	if q := m.search.input.Value(); q != m.search.query {
+		m.search.query = q
+		m.search.current = 0
+		m.syncViewport()
+		m.jumpToMatch(0)
+	}
+	return m, cmd
+}
+
+// jumpToMatch moves the selection by delta matches, wrapping around, and
+// scrolls it into view.
+func (m *Model) jumpToMatch(delta int) {
+	n := len(m.search.matches)
+	if n == 0 {
+		return
+	}
+	m.search.current = ((m.search.current+delta)%n + n) % n
+	line := m.search.currentLine()
+	offset := line - m.viewport.Height/2
+	if offset < 0 {
+		offset = 0
+	}
+	m.viewport.SetYOffset(offset)
+	m.cur().follow = false
+}
+
+// selectTool picks the tool call delta calls away from the selected one and
+// scrolls it into view.
+func (m *Model) selectTool(delta int) {
+	t := m.cur()
+	i := t.toolIndex(delta)
+	if i < 0 || i >= len(m.starts) {
+		return
+	}
+	t.selected = i
+	offset := m.starts[i] - m.viewport.Height/2
+	if offset < 0 {
+		offset = 0
+	}
+	m.viewport.SetYOffset(offset)
+	t.follow = false
+}
+
+// syncViewport brings the viewport up to date with the current tab's chat,
+// re-rendering only what changed, and resizes it to the space left by the
+// status bar and the footer.
+func (m *Model) syncViewport() {
+	m.viewport.Width = m.width
+	m.viewport.Height = m.chatHeight()
+
+	md := m.md
+	if m.rawOutput {
+		md = nil
+	}
+	t := m.cur()
+	content, starts, matches := t.view.render(t, m.width, md, m.search.query, m.search.current)
+	m.starts = starts
+	m.search.matches = matches
+	if m.search.current >= len(m.search.matches) {
+		m.search.current = 0
+	}
+
+	if content != m.content {
+		m.content = content
+		m.viewport.SetContent(content)
+	}
+	if t.follow {
+		m.viewport.GotoBottom()
+	}
+}
+
+func (m Model) chatHeight() int {
+	// Status bar, the blank line below it and the line above the footer,
+	// which doubles as the search bar
+	used := 3
+	if len(m.tabs) > 1 {
+		used++
+	}
+	if t := m.cur(); t.permReq != nil {
+		used += lipgloss.Height(renderPermission(t.permReq, t.permRule, m.width))
+	} else {
+		used += m.input.Height()
+	}
+	h := m.height - used
+	if h < 1 {
+		h = 1
+	}
+	return h
+}
+
+func (m Model) searchLine() string {
+	switch {
+	case m.search.typing:
+		return m.search.input.View() + searchHintStyle.Render(matchCount(m.search))
+	case m.search.query != "":
+		return searchHintStyle.Render("/"+m.search.query+matchCount(m.search)) +
+			searchHintStyle.Render("  n/N next/prev · Esc clear")
+	default:
+		return searchHintStyle.Render("-- HISTORY --  ↑/↓ scroll · / search · t/T tool calls · o expand · Esc back to input")
+	}
+}
+
+func matchCount(s search) string {
+	if s.query == "" {
+		return ""
+	}
+	if len(s.matches) == 0 {
+		return "  no matches"
+	}
+	return fmt.Sprintf("  %d/%d", s.current+1, len(s.matches))
 }
 
 func (m Model) handlePermissionKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	t := m.cur()
 	switch msg.String() {
 	case "y", "Y":
-		m.ws.Send(client.NewPermissionResponse(m.sessionID, m.permReq.RequestID, true))
-		m.messages = append(m.messages, chatMessage{
+		m.log.Info("permission allowed by user", "session", t.sessionID, "tool", t.permReq.ToolName)
+		m.sender.send(client.NewPermissionResponse(t.sessionID, t.permReq.RequestID, true))
+		t.messages = append(t.messages, chatMessage{
 			Role:    "assistant",
-			Content: "✓ Allowed: " + m.permReq.ToolName,
+			Content: "✓ Allowed: " + t.permReq.ToolName,
 		})
-		m.permReq = nil
+		t.permReq = nil
 	case "n", "N":
-		m.ws.Send(client.NewPermissionResponse(m.sessionID, m.permReq.RequestID, false))
-		m.messages = append(m.messages, chatMessage{
+		m.log.Info("permission denied by user", "session", t.sessionID, "tool", t.permReq.ToolName)
+		m.sender.send(client.NewPermissionResponse(t.sessionID, t.permReq.RequestID, false))
+		t.messages = append(t.messages, chatMessage{
 			Role:    "error",
-			Content: "✗ Denied: " + m.permReq.ToolName,
+			Content: "✗ Denied: " + t.permReq.ToolName,
 		})
-		m.permReq = nil
+		t.permReq = nil
 	}
 	return m, nil
 }
 
-func (m Model) handleWS(data []byte) (tea.Model, tea.Cmd) {
-	msgType, parsed, err := client.ParseServerMessage(data)
-	if err != nil {
-		m.messages = append(m.messages, chatMessage{Role: "error", Content: err.Error()})
-		return m, listenWS(m.ws)
-	}
+func (m Model) handleEvent(ev client.Event) (tea.Model, tea.Cmd) {
+	switch ev := ev.(type) {
+	case client.Reconnecting:
+		m.connected = false
+		m.reconnecting = &ev
+		m.rtt = 0
+
+	case client.Reconnected:
+		m.connected = true
+		m.reconnecting = nil
+		cmds := []tea.Cmd{m.listen()}
+		// Messages the connection lost wait in the outbox until the tab
+		// has caught up, so they follow what the server did meanwhile
+		for _, t := range m.tabs {
+			t.syncing = true
+			m.sender.send(client.NewSwitchSession(t.sessionID))
+			cmds = append(cmds, resync(m.rest, t.sessionID, t.lastSeq))
+		}
+		return m, tea.Batch(cmds...)
 
-	switch msgType {
-	case "assistant_chunk":
-		chunk := parsed.(*client.AssistantChunk)
-		m.streamBuf += chunk.Content
+	case client.QueueChanged:
+		m.queued = ev.Depth
 
-	case "assistant_message":
-		msg := parsed.(*client.AssistantMessageMsg)
-		content := msg.Content
-		if content == "" {
-			content = m.streamBuf
+	case client.Latency:
+		m.rtt = ev.RTT
+
+	case client.Disconnected:
+		m.log.Error("connection lost", "err", ev.Err)
+
+	case client.SeqGap:
+		t := m.findTab(ev.SessionID)
+		if t == nil {
+			break
+		}
+		if ev.Running {
+			// The text comes again with the final reply, but nothing
+			// brings back a tool call or a permission request; the server
+			// can only tell whether the turn is still going
+			t.messages = append(t.messages, chatMessage{Role: "info", Content: fmt.Sprintf(
+				"Missed frames %d-%d of the running answer. Its text comes with the final reply, but tool calls among them are lost. "+
+					"If one was a permission request, the answer waits for it: press Ctrl+C to interrupt it.", ev.From, ev.To)})
+			m.sender.send(client.NewSwitchSession(t.sessionID))
+			break
 		}
-		m.messages = append(m.messages, chatMessage{Role: "assistant", Content: content})
-		m.streamBuf = ""
+		// The reply may be among the missed frames; history has it
+		t.messages = append(t.messages, chatMessage{Role: "info", Content: fmt.Sprintf(
+			"Missed frames %d-%d of the last answer. Its reply is complete, but tool calls among them are lost.", ev.From, ev.To)})
+		return m, tea.Batch(m.listen(), resync(m.rest, t.sessionID, ev.From-1))
 
-	case "permission_request":
-		req := parsed.(*client.PermissionRequest)
-		m.permReq = req
+	case client.Rejected:
+		m.reject(ev)
 
-	case "session_state":
-		state := parsed.(*client.SessionState)
-		m.status = state.Status
+	case client.BadFrame:
+		m.log.Warn("unreadable frame", "err", ev.Err)
+		m.cur().messages = append(m.cur().messages, chatMessage{Role: "error", Content: ev.Err.Error()})
 
-	case "result":
-		result := parsed.(*client.ResultMessage)
-		if !result.Success && result.Error != "" {
-			m.messages = append(m.messages, chatMessage{Role: "error", Content: result.Error})
-		}
+	default:
+		return m.handleFrame(ev)
 	}
+	return m, m.listen()
+}
+
+func (m Model) listen() tea.Cmd {
+	return listenWS(m.conn)
+}
 
-	return m, listenWS(m.ws)
+func (m Model) handleFrame(ev client.Event) (tea.Model, tea.Cmd) {
+	// Frames without a session, such as rate-limit errors, go to the tab
+	// in front; frames for closed tabs are dropped
+	t := m.cur()
+	if sid := client.FrameSessionID(ev); sid != "" {
+		t = m.findTab(sid)
+	}
+	if t == nil {
+		m.log.Debug("dropped frame for closed tab", "type", fmt.Sprintf("%T", ev), "session", client.FrameSessionID(ev))
+		return m, m.listen()
+	}
+	t.handleFrame(m.sender, m.paths, m.log, ev)
+	if _, state := ev.(*client.SessionState); t != m.cur() && !state {
+		t.unread = true
+	}
+	return m, m.listen()
 }
 
 func (m Model) View() string {
@@ -213,22 +901,31 @@ func (m Model) View() string {
 	var b strings.Builder
 
 	// Status bar at top
-	b.WriteString(renderStatusBar(m.connected, m.status, m.server, m.width))
-	b.WriteString("\n\n")
+	t := m.cur()
+	b.WriteString(renderStatusBar(m.connected, m.reconnecting, m.rtt, m.queued, t.status, m.server, m.width))
+	b.WriteString("\n")
+	if len(m.tabs) > 1 {
+		b.WriteString(renderTabBar(m.tabs, m.active, m.width))
+		b.WriteString("\n")
+	}
+	b.WriteString("\n")
 
 	// Chat area
-	chatContent := renderChat(m.messages, m.streamBuf, m.width)
-	b.WriteString(chatContent)
+	b.WriteString(m.viewport.View())
+	b.WriteString("\n")
+
+	if m.search.active() || m.browsing {
+		b.WriteString(m.searchLine())
+	}
+	b.WriteString("\n")
 
 	// Permission overlay
-	if m.permReq != nil {
-		b.WriteString(renderPermission(m.permReq, m.width))
-		b.WriteString("\n")
+	if t.permReq != nil {
+		b.WriteString(renderPermission(t.permReq, t.permRule, m.width))
 	}
 
 	// Input
-	if m.permReq == nil {
-		b.WriteString("\n")
+	if t.permReq == nil {
 		b.WriteString(inputPrefixStyle.Render("> "))
 		b.WriteString(m.input.View())
 	}
@@ -0,0 +1,209 @@
+package tui
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/arvid/remote-ai-ide/cli/internal/client"
+	"github.com/arvid/remote-ai-ide/cli/internal/outbox"
+)
+
+// post sends text typed in t. While connected, with nothing pending ahead
+// of it, it goes straight to the sender, which puts it in the outbox only
+// if the write fails; otherwise it waits in the outbox behind the rest.
+func (m *Model) post(t *tab, text string) {
+	if m.connected && !t.syncing && !t.hasPending() {
+		t.messages = append(t.messages, chatMessage{Role: "user", Content: text})
+		m.sender.sendHeld(client.NewUserMessage(t.sessionID, text))
+		return
+	}
+	m.hold(t, text)
+	if m.connected {
+		m.flush(t)
+	}
+}
+
+// hold puts text in the outbox, so one typed while disconnected is sent
+// later rather than dropped.
+func (m *Model) hold(t *tab, text string) {
+	p, err := m.outbox.Add(t.sessionID, text)
+	t.messages = append(t.messages, chatMessage{Role: "user", Content: text, Pending: p.ID})
+	if err != nil {
+		m.log.Warn("outbox not saved", "err", err)
+		t.messages = append(t.messages, chatMessage{Role: "error", Content: err.Error() + "; the message is kept until you quit"})
+	}
+}
+
+// flush sends t's pending messages in order, stopping at one being edited.
+// Each stays in the outbox until the sender has written it. Nothing goes
+// while t resyncs after a reconnect; the resync flushes once done.
+func (m *Model) flush(t *tab) {
+	if t.syncing {
+		return
+	}
+	for _, p := range m.outbox.Session(t.sessionID) {
+		if p.ID == t.editing {
+			return
+		}
+		if !m.outbox.Take(p.ID) {
+			// Another connect has taken it since
+			continue
+		}
+		if i := t.pendingIndex(p.ID); i >= 0 {
+			t.messages[i].Sending = true
+		} else {
+			// Left over from an earlier run
+			t.messages = append(t.messages, chatMessage{Role: "user", Content: p.Text, Pending: p.ID, Sending: true})
+		}
+		msg := client.NewUserMessage(t.sessionID, p.Text)
+		msg.Seq = p.Seq
+		m.sender.sendPending(msg, p.ID)
+	}
+}
+
+// settle applies the outcome of writing a message from the outbox to its
+// chat entry. A failed one is pending again and goes with the next flush,
+// as does a held one the sender has put in the outbox.
+func (m *Model) settle(r sendResultMsg) {
+	um := r.msg.(client.UserMessage)
+	if r.saveErr != nil {
+		m.log.Warn("outbox not saved", "err", r.saveErr)
+	}
+	if r.held {
+		m.log.Warn("message not sent; kept in the outbox", "session", um.SessionID, "id", r.pending, "err", r.err)
+		t := m.findTab(um.SessionID)
+		if t == nil {
+			return
+		}
+		if i := t.unconfirmedUser(um.Text); i >= 0 {
+			t.messages[i].Pending = r.pending
+		} else {
+			t.messages = append(t.messages, chatMessage{Role: "user", Content: um.Text, Pending: r.pending})
+		}
+		if r.saveErr != nil {
+			t.messages = append(t.messages, chatMessage{Role: "error", Content: r.saveErr.Error() + "; the message is kept until you quit"})
+		}
+		if m.connected {
+			m.flush(t)
+		}
+		return
+	}
+	if r.err != nil {
+		m.log.Warn("message not sent; back in the outbox", "session", um.SessionID, "id", r.pending, "err", r.err)
+	} else {
+		m.log.Info("sent pending message", "session", um.SessionID, "id", r.pending)
+	}
+	t := m.findTab(um.SessionID)
+	if t == nil {
+		return
+	}
+	if i := t.pendingIndex(r.pending); i >= 0 {
+		t.messages[i].Sending = false
+		if r.err == nil {
+			t.messages[i].Pending = 0
+		}
+	}
+}
+
+// reject puts a message the client gave up on back in the outbox. One
+// lost with the connection goes once the tab has resynced; one the rate
+// limit kept turning away goes first with the next message.
+func (m *Model) reject(r client.Rejected) {
+	um := r.Message
+	m.log.Warn("message rejected by the rate limit; back in the outbox", "session", um.SessionID, "seq", um.Seq, "lost", r.Lost)
+	t := m.findTab(um.SessionID)
+	if t == nil {
+		// Its tab is closed; the next connect to the session sends it
+		if _, err := m.outbox.Readd(um.SessionID, um.Text, um.Seq); err != nil {
+			m.log.Warn("outbox not saved", "err", err)
+		}
+		return
+	}
+	p, err := m.outbox.Readd(t.sessionID, um.Text, um.Seq)
+	if i := t.unconfirmedUser(um.Text); i >= 0 {
+		t.messages[i].Pending = p.ID
+		t.messages[i].Sending = false
+	} else {
+		t.messages = append(t.messages, chatMessage{Role: "user", Content: um.Text, Pending: p.ID})
+	}
+	if !r.Lost {
+		t.messages = append(t.messages, chatMessage{Role: "info", Content: "The server's rate limit kept turning this message away. " +
+			"It is back in the outbox and goes first with your next message; /discard drops it."})
+	}
+	if err != nil {
+		m.log.Warn("outbox not saved", "err", err)
+		t.messages = append(t.messages, chatMessage{Role: "error", Content: err.Error() + "; the message is kept until you quit"})
+	}
+}
+
+// listOutbox describes t's pending messages for /outbox.
+func (m *Model) listOutbox(t *tab) string {
+	pending := m.outbox.Session(t.sessionID)
+	if len(pending) == 0 {
+		return "No pending messages."
+	}
+	var b strings.Builder
+	b.WriteString("Pending messages, sent in this order once connected:\n")
+	for i, p := range pending {
+		line, _, more := strings.Cut(p.Text, "\n")
+		if more {
+			line += " …"
+		}
+		fmt.Fprintf(&b, "  %d. %s (%s)\n", i+1, truncate(line, 60), p.Created.Format("15:04"))
+	}
+	b.WriteString("Change one with /edit n, drop one with /discard n.")
+	return b.String()
+}
+
+// pickPending resolves the argument of /edit and /discard: a 1-based
+// position in t's pending messages, defaulting to the last.
+func (m *Model) pickPending(t *tab, arg string) (outbox.Message, error) {
+	pending := m.outbox.Session(t.sessionID)
+	if len(pending) == 0 {
+		return outbox.Message{}, fmt.Errorf("no pending messages")
+	}
+	if arg == "" {
+		return pending[len(pending)-1], nil
+	}
+	n, err := strconv.Atoi(arg)
+	if err != nil || n < 1 || n > len(pending) {
+		return outbox.Message{}, fmt.Errorf("no pending message %s; see /outbox", arg)
+	}
+	return pending[n-1], nil
+}
+
+// saveEdit stores the composer's text as the new version of the pending
+// message being edited.
+func (m *Model) saveEdit(t *tab, text string) {
+	id := t.editing
+	t.editing = 0
+	if err := m.outbox.Update(id, text); err != nil {
+		t.messages = append(t.messages, chatMessage{Role: "error", Content: err.Error()})
+		return
+	}
+	if i := t.pendingIndex(id); i >= 0 {
+		t.messages[i].Content = text
+	}
+	if m.connected {
+		m.flush(t)
+	}
+}
+
+// discard drops pending message p from the outbox and the chat.
+func (m *Model) discard(t *tab, p outbox.Message) {
+	if err := m.outbox.Remove(p.ID); err != nil {
+		t.messages = append(t.messages, chatMessage{Role: "error", Content: err.Error()})
+		return
+	}
+	if i := t.pendingIndex(p.ID); i >= 0 {
+		t.messages = append(t.messages[:i], t.messages[i+1:]...)
+	}
+	if t.editing == p.ID {
+		t.editing = 0
+	}
+	if m.connected {
+		// An edit may have been holding back the messages after it
+		m.flush(t)
+	}
+}
@@ -0,0 +1,69 @@
+package tui
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+)
+
+// PathMapper translates paths between this machine and the server.
+// *config.Server implements it with its path mappings.
+type PathMapper interface {
+	ToRemote(path string) string
+	ToLocal(path string) string
+}
+
+// identityPaths is used when no mapper is configured.
+type identityPaths struct{}
+
+func (identityPaths) ToRemote(path string) string { return path }
+func (identityPaths) ToLocal(path string) string  { return path }
+
+// localizeInput rewrites absolute server paths among a tool input's string
+// values to local paths for display. The input is returned untouched when
+// nothing maps, so key order and formatting are kept.
+func localizeInput(paths PathMapper, raw json.RawMessage) json.RawMessage {
+	var v interface{}
+	if len(raw) == 0 || json.Unmarshal(raw, &v) != nil {
+		return raw
+	}
+	v, changed := localizeValue(paths, v)
+	if !changed {
+		return raw
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return raw
+	}
+	return bytes.TrimRight(buf.Bytes(), "\n")
+}
+
+func localizeValue(paths PathMapper, v interface{}) (interface{}, bool) {
+	switch v := v.(type) {
+	case string:
+		if !strings.HasPrefix(v, "/") {
+			return v, false
+		}
+		local := paths.ToLocal(v)
+		return local, local != v
+	case map[string]interface{}:
+		changed := false
+		for k, item := range v {
+			mapped, ok := localizeValue(paths, item)
+			v[k] = mapped
+			changed = changed || ok
+		}
+		return v, changed
+	case []interface{}:
+		changed := false
+		for i, item := range v {
+			mapped, ok := localizeValue(paths, item)
+			v[i] = mapped
+			changed = changed || ok
+		}
+		return v, changed
+	}
+	return v, false
+}
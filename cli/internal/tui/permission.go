@@ -7,7 +7,7 @@ import (
 	"github.com/arvid/remote-ai-ide/cli/internal/client"
 )
 
-func renderPermission(req *client.PermissionRequest, width int) string {
+func renderPermission(req *client.PermissionRequest, rule string, width int) string {
 	var b strings.Builder
 
 	title := permTitleStyle.Render("⚠ Permission Request")
@@ -19,7 +19,11 @@ func renderPermission(req *client.PermissionRequest, width int) string {
 
 	b.WriteString(title + "\n\n")
 	b.WriteString("Tool: " + tool + "\n")
-	b.WriteString("Description: " + desc + "\n\n")
+	b.WriteString("Description: " + desc + "\n")
+	if rule != "" {
+		b.WriteString("Rule: " + rule + "\n")
+	}
+	b.WriteString("\n")
 
 	input := formatToolInput(req.ToolInput)
 	if input != "" && input != "null" {
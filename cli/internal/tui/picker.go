@@ -0,0 +1,89 @@
+package tui
+
+import (
+	"errors"
+
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// ErrPickerCancelled is returned by Pick when the user quits without choosing.
+var ErrPickerCancelled = errors.New("selection cancelled")
+
+// PickerItem is a single choice offered by Pick.
+type PickerItem struct {
+	ID    string
+	Label string
+	Desc  string
+}
+
+func (i PickerItem) Title() string       { return i.Label }
+func (i PickerItem) Description() string { return i.Desc }
+func (i PickerItem) FilterValue() string { return i.Label + " " + i.Desc }
+
+type pickerModel struct {
+	list     list.Model
+	choice   string
+	quitting bool
+}
+
+func (m pickerModel) Init() tea.Cmd {
+	return nil
+}
+
+func (m pickerModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.list.SetSize(msg.Width, msg.Height-1)
+		return m, nil
+
+	case tea.KeyMsg:
+		if m.list.FilterState() == list.Filtering {
+			break
+		}
+		switch msg.Type {
+		case tea.KeyEnter:
+			if item, ok := m.list.SelectedItem().(PickerItem); ok {
+				m.choice = item.ID
+			}
+			m.quitting = true
+			return m, tea.Quit
+		case tea.KeyCtrlC, tea.KeyEsc:
+			m.quitting = true
+			return m, tea.Quit
+		}
+	}
+
+	var cmd tea.Cmd
+	m.list, cmd = m.list.Update(msg)
+	return m, cmd
+}
+
+func (m pickerModel) View() string {
+	if m.quitting {
+		return ""
+	}
+	return m.list.View()
+}
+
+// Pick shows a filterable list and returns the ID of the chosen item.
+func Pick(title string, items []PickerItem) (string, error) {
+	listItems := make([]list.Item, len(items))
+	for i, it := range items {
+		listItems[i] = it
+	}
+
+	l := list.New(listItems, list.NewDefaultDelegate(), 0, 0)
+	l.Title = title
+	l.SetStatusBarItemName("item", "items")
+
+	res, err := tea.NewProgram(pickerModel{list: l}, tea.WithAltScreen()).Run()
+	if err != nil {
+		return "", err
+	}
+	choice := res.(pickerModel).choice
+	if choice == "" {
+		return "", ErrPickerCancelled
+	}
+	return choice, nil
+}
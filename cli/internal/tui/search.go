@@ -0,0 +1,92 @@
+package tui
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// search holds the state of the / search over the chat history.
+type search struct {
+	input   textinput.Model
+	typing  bool   // the query is being edited
+	query   string // active query; empty when no search is active
+	matches []int  // content line of each matching line
+	current int    // index into matches
+}
+
+func newSearch() search {
+	ti := textinput.New()
+	ti.Prompt = "/"
+	ti.Placeholder = "search"
+	return search{input: ti}
+}
+
+func (s *search) start() {
+	s.typing = true
+	s.input.SetValue(s.query)
+	s.input.CursorEnd()
+	s.input.Focus()
+}
+
+func (s *search) clear() {
+	s.typing = false
+	s.query = ""
+	s.matches = nil
+	s.current = 0
+	s.input.Blur()
+	s.input.Reset()
+}
+
+func (s *search) active() bool {
+	return s.typing || s.query != ""
+}
+
+// currentLine returns the content line of the selected match, or -1.
+func (s *search) currentLine() int {
+	if len(s.matches) == 0 {
+		return -1
+	}
+	return s.matches[s.current]
+}
+
+// highlightMatches marks every case-insensitive occurrence of query in the
+// rendered content and returns the index of each line containing one.
+// Matching lines lose their own styling so the highlight stays readable.
+func highlightMatches(content, query string, current int) (string, []int) {
+	if query == "" {
+		return content, nil
+	}
+	// Matching on the line itself: lowercasing it first can change byte
+	// lengths, and offsets into the lowered copy do not fit the original
+	re := regexp.MustCompile("(?i)" + regexp.QuoteMeta(query))
+	lines := strings.Split(content, "\n")
+	var matches []int
+	for i, line := range lines {
+		plain := ansi.Strip(line)
+		if !re.MatchString(plain) {
+			continue
+		}
+		style := searchMatchStyle
+		if len(matches) == current {
+			style = searchCurrentStyle
+		}
+		matches = append(matches, i)
+		lines[i] = highlightLine(plain, re, style.Render)
+	}
+	return strings.Join(lines, "\n"), matches
+}
+
+func highlightLine(plain string, re *regexp.Regexp, mark func(...string) string) string {
+	var b strings.Builder
+	last := 0
+	for _, loc := range re.FindAllStringIndex(plain, -1) {
+		b.WriteString(plain[last:loc[0]])
+		b.WriteString(mark(plain[loc[0]:loc[1]]))
+		last = loc[1]
+	}
+	b.WriteString(plain[last:])
+	return b.String()
+}
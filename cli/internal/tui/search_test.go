@@ -0,0 +1,57 @@
+package tui
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/x/ansi"
+)
+
+func TestHighlightMatches(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		query   string
+		want    []int
+	}{
+		{"none", "alpha\nbeta", "gamma", nil},
+		{"empty query", "alpha\nbeta", "", nil},
+		{"case-insensitive", "Alpha\nbeta\nALPHA", "alpha", []int{0, 2}},
+		{"inside styled text", "\x1b[1mal\x1b[22mpha\nbeta", "alpha", []int{0}},
+		{"escape codes are not text", "\x1b[31mred\x1b[0m\nplain", "31m", nil},
+		{"non-ASCII", "Überblick\nueber", "üBER", []int{0}},
+		{"regexp characters", "a.b\naxb", "a.b", []int{0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, got := highlightMatches(tt.content, tt.query, 0)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("highlightMatches(%q, %q) lines = %v, want %v", tt.content, tt.query, got, tt.want)
+			}
+			if ansi.Strip(out) != ansi.Strip(tt.content) {
+				t.Errorf("highlightMatches(%q, %q) changed the text to %q", tt.content, tt.query, ansi.Strip(out))
+			}
+		})
+	}
+}
+
+func TestHighlightLine(t *testing.T) {
+	mark := func(s ...string) string { return "[" + strings.Join(s, "") + "]" }
+	tests := []struct {
+		line  string
+		query string
+		want  string
+	}{
+		{"foo bar foo", "foo", "[foo] bar [foo]"},
+		{"FooFOO", "foo", "[Foo][FOO]"},
+		{"Überblick", "über", "[Über]blick"},
+	}
+	for _, tt := range tests {
+		re := regexp.MustCompile("(?i)" + regexp.QuoteMeta(tt.query))
+		if got := highlightLine(tt.line, re, mark); got != tt.want {
+			t.Errorf("highlightLine(%q, %q) = %q, want %q", tt.line, tt.query, got, tt.want)
+		}
+	}
+}
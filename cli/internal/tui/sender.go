@@ -0,0 +1,122 @@
+package tui
+
+import (
+	"context"
+	"sync"
+
+	"github.com/arvid/remote-ai-ide/cli/internal/client"
+	"github.com/arvid/remote-ai-ide/cli/internal/outbox"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// sender writes frames from a goroutine of its own, in the order they were
+// given, since Conn.Send blocks while the rate limit holds a frame back and
+// Update must not. Its queue is unbounded for the same reason: however
+// long a send is stuck, handing it another frame returns at once.
+type sender struct {
+	mu      sync.Mutex
+	queue   []outgoing
+	wake    chan struct{}
+	results chan sendResultMsg
+	outbox  *outbox.Outbox
+}
+
+// outgoing is a frame for the sender; pending is the outbox ID of a user
+// message, which stays in the outbox until it is written. A user message
+// sent straight away is held, put in the outbox only should its write fail.
+type outgoing struct {
+	msg     client.ClientMessage
+	pending int
+	hold    bool
+}
+
+// sendResultMsg reports a user message from the outbox that was written
+// or failed, a held one that failed and is now in the outbox as pending,
+// or any other frame that failed.
+type sendResultMsg struct {
+	msg     client.ClientMessage
+	pending int
+	held    bool
+	err     error
+	saveErr error // the outbox could not be saved
+}
+
+func newSender(conn *client.Conn, ob *outbox.Outbox) *sender {
+	s := &sender{
+		wake:    make(chan struct{}, 1),
+		results: make(chan sendResultMsg, 64),
+		outbox:  ob,
+	}
+	go func() {
+		for {
+			o := s.next()
+			err := conn.Send(context.Background(), o.msg)
+			r := sendResultMsg{msg: o.msg, pending: o.pending, err: err}
+			if o.pending != 0 {
+				// Settled here rather than in Update, so the outbox is
+				// right even once the TUI has quit
+				if err == nil {
+					r.saveErr = s.outbox.Remove(o.pending)
+				} else {
+					s.outbox.Return(o.pending)
+				}
+			} else if err != nil && o.hold {
+				um := o.msg.(client.UserMessage)
+				p, saveErr := s.outbox.Readd(um.SessionID, um.Text, um.Seq)
+				r.pending, r.held, r.saveErr = p.ID, true, saveErr
+			} else if err == nil {
+				continue
+			}
+			s.results <- r
+		}
+	}()
+	return s
+}
+
+// next waits for the oldest frame not yet sent and takes it.
+func (s *sender) next() outgoing {
+	for {
+		s.mu.Lock()
+		if len(s.queue) > 0 {
+			o := s.queue[0]
+			s.queue = s.queue[1:]
+			s.mu.Unlock()
+			return o
+		}
+		s.mu.Unlock()
+		<-s.wake
+	}
+}
+
+func (s *sender) push(o outgoing) {
+	s.mu.Lock()
+	s.queue = append(s.queue, o)
+	s.mu.Unlock()
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (s *sender) send(m client.ClientMessage) {
+	s.push(outgoing{msg: m})
+}
+
+// sendHeld sends a user message typed while connected, keeping it in the
+// outbox only if the write fails.
+func (s *sender) sendHeld(m client.UserMessage) {
+	s.push(outgoing{msg: m, hold: true})
+}
+
+// sendPending sends a user message taken from the outbox as id.
+func (s *sender) sendPending(m client.UserMessage, id int) {
+	s.push(outgoing{msg: m, pending: id})
+}
+
+// listenSender waits for the next send result. It is kept listening for
+// the life of the TUI, whatever the connection does, so no result is lost.
+func listenSender(s *sender) tea.Cmd {
+	return func() tea.Msg {
+		return <-s.results
+	}
+}
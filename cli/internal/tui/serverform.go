@@ -0,0 +1,282 @@
+package tui
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// ErrFormCancelled is returned by ServerForm when the user quits.
+var ErrFormCancelled = errors.New("cancelled")
+
+// ErrCheckLater is returned by CheckToken for a token that cannot be
+// checked while the form holds the terminal, such as a reference that may
+// prompt for a passphrase. The form accepts it; the caller checks it once
+// ServerForm has returned.
+var ErrCheckLater = errors.New("token checked after the form")
+
+// ServerFormOptions configures ServerForm. The check functions run in the
+// background when the form is submitted; an error is shown under the field
+// it concerns and the form stays open.
+type ServerFormOptions struct {
+	Title string
+
+	// Initial values. EditName false shows Name without letting it change.
+	Name     string
+	URL      string
+	EditName bool
+
+	// TokenHint is shown in an empty token field, e.g. to say that leaving
+	// it empty keeps the current token.
+	TokenHint     string
+	TokenOptional bool
+
+	ValidateName func(name string) error
+	CheckURL     func(url string) error
+	CheckToken   func(url, token string) error
+
+	// AskDefault offers to make the server the default once it checks out.
+	AskDefault bool
+}
+
+// ServerFormResult holds what the user entered.
+type ServerFormResult struct {
+	Name        string
+	URL         string
+	Token       string
+	MakeDefault bool
+}
+
+const (
+	fieldName = iota
+	fieldURL
+	fieldToken
+)
+
+var fieldLabels = []string{"Name", "URL", "Token"}
+
+type checkDoneMsg struct {
+	field int
+	err   error
+	later bool // the token is left for the caller to check
+}
+
+type serverFormModel struct {
+	opts     ServerFormOptions
+	inputs   []textinput.Model
+	errs     []string
+	focus    int
+	checking bool
+	asking   bool // waiting for the make-default answer
+	later    bool // the token was not checked
+	result   *ServerFormResult
+	done     bool
+}
+
+func newServerForm(opts ServerFormOptions) serverFormModel {
+	m := serverFormModel{opts: opts, errs: make([]string, len(fieldLabels))}
+	for range fieldLabels {
+		ti := textinput.New()
+		ti.Prompt = ""
+		ti.Width = 50
+		m.inputs = append(m.inputs, ti)
+	}
+	m.inputs[fieldName].SetValue(opts.Name)
+	m.inputs[fieldURL].SetValue(opts.URL)
+	m.inputs[fieldURL].Placeholder = "http://localhost:3002"
+	m.inputs[fieldToken].EchoMode = textinput.EchoPassword
+	m.inputs[fieldToken].Placeholder = opts.TokenHint
+	if !opts.EditName {
+		m.focus = fieldURL
+	}
+	m.inputs[m.focus].Focus()
+	return m
+}
+
+func (m serverFormModel) Init() tea.Cmd {
+	return textinput.Blink
+}
+
+func (m serverFormModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case checkDoneMsg:
+		m.checking = false
+		if msg.err != nil {
+			m.errs[msg.field] = msg.err.Error()
+			return m, m.setFocus(msg.field)
+		}
+		m.later = msg.later
+		if m.opts.AskDefault {
+			m.asking = true
+			return m, nil
+		}
+		return m.finish(false)
+
+	case tea.KeyMsg:
+		if msg.Type == tea.KeyCtrlC || msg.Type == tea.KeyEsc {
+			m.done = true
+			return m, tea.Quit
+		}
+		if m.asking {
+			switch strings.ToLower(msg.String()) {
+			case "y":
+				return m.finish(true)
+			case "n", "enter":
+				return m.finish(false)
+			}
+			return m, nil
+		}
+		if m.checking {
+			return m, nil
+		}
+		switch msg.String() {
+		case "tab", "down":
+			return m, m.setFocus(m.next(1))
+		case "shift+tab", "up":
+			return m, m.setFocus(m.next(-1))
+		case "enter":
+			if m.focus != fieldToken {
+				return m, m.setFocus(m.next(1))
+			}
+			return m.submit()
+		}
+	}
+
+	var cmd tea.Cmd
+	m.inputs[m.focus], cmd = m.inputs[m.focus].Update(msg)
+	if _, ok := msg.(tea.KeyMsg); ok {
+		// Editing a field dismisses its error
+		m.errs[m.focus] = ""
+	}
+	return m, cmd
+}
+
+// next returns the field delta steps away, skipping a fixed name.
+func (m serverFormModel) next(delta int) int {
+	first := fieldName
+	if !m.opts.EditName {
+		first = fieldURL
+	}
+	n := fieldToken - first + 1
+	return first + ((m.focus-first+delta)%n+n)%n
+}
+
+func (m *serverFormModel) setFocus(i int) tea.Cmd {
+	m.inputs[m.focus].Blur()
+	m.focus = i
+	return m.inputs[i].Focus()
+}
+
+// submit validates the fields locally, then runs the server checks.
+func (m serverFormModel) submit() (tea.Model, tea.Cmd) {
+	name := strings.TrimSpace(m.inputs[fieldName].Value())
+	url := strings.TrimRight(strings.TrimSpace(m.inputs[fieldURL].Value()), "/")
+	token := strings.TrimSpace(m.inputs[fieldToken].Value())
+
+	var err error
+	field := fieldName
+	switch {
+	case name == "":
+		err = fmt.Errorf("name is required")
+	case m.opts.EditName && m.opts.ValidateName != nil:
+		err = m.opts.ValidateName(name)
+	}
+	if err == nil {
+		field = fieldURL
+		if url == "" {
+			err = fmt.Errorf("URL is required")
+		}
+	}
+	if err == nil && token == "" && !m.opts.TokenOptional {
+		field, err = fieldToken, fmt.Errorf("token is required")
+	}
+	if err != nil {
+		m.errs[field] = err.Error()
+		return m, m.setFocus(field)
+	}
+
+	m.checking = true
+	opts := m.opts
+	return m, func() tea.Msg {
+		if opts.CheckURL != nil {
+			if err := opts.CheckURL(url); err != nil {
+				return checkDoneMsg{field: fieldURL, err: err}
+			}
+		}
+		if opts.CheckToken != nil {
+			err := opts.CheckToken(url, token)
+			if errors.Is(err, ErrCheckLater) {
+				return checkDoneMsg{later: true}
+			}
+			if err != nil {
+				return checkDoneMsg{field: fieldToken, err: err}
+			}
+		}
+		return checkDoneMsg{}
+	}
+}
+
+func (m serverFormModel) finish(makeDefault bool) (tea.Model, tea.Cmd) {
+	m.result = &ServerFormResult{
+		Name:        strings.TrimSpace(m.inputs[fieldName].Value()),
+		URL:         strings.TrimRight(strings.TrimSpace(m.inputs[fieldURL].Value()), "/"),
+		Token:       strings.TrimSpace(m.inputs[fieldToken].Value()),
+		MakeDefault: makeDefault,
+	}
+	m.done = true
+	return m, tea.Quit
+}
+
+func (m serverFormModel) View() string {
+	if m.done {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString(permTitleStyle.Render(m.opts.Title) + "\n\n")
+	for i, label := range fieldLabels {
+		marker := "  "
+		if i == m.focus && !m.asking {
+			marker = inputPrefixStyle.Render("> ")
+		}
+		value := m.inputs[i].View()
+		if i == fieldName && !m.opts.EditName {
+			value = m.inputs[i].Value()
+		}
+		fmt.Fprintf(&b, "%s%-6s %s\n", marker, label, value)
+		if m.errs[i] != "" {
+			fmt.Fprintf(&b, "         %s\n", errorStyle.Render("✗ "+m.errs[i]))
+		}
+	}
+	b.WriteString("\n")
+	switch {
+	case m.checking:
+		b.WriteString(searchHintStyle.Render("Checking the server...") + "\n")
+	case m.asking:
+		if m.later {
+			b.WriteString(statusConnected.Render("✓ Server OK") + searchHintStyle.Render(" (the token is checked next)") + "\n")
+		} else {
+			b.WriteString(statusConnected.Render("✓ Server and token OK") + "\n")
+		}
+		fmt.Fprintf(&b, "Make %s the default server? [y/N] ", strings.TrimSpace(m.inputs[fieldName].Value()))
+	default:
+		b.WriteString(searchHintStyle.Render("enter next/save · tab move · esc cancel") + "\n")
+	}
+	return b.String()
+}
+
+// ServerForm asks for a server's name, URL and token, checking them
+// against the server before returning.
+func ServerForm(opts ServerFormOptions) (*ServerFormResult, error) {
+	res, err := tea.NewProgram(newServerForm(opts)).Run()
+	if err != nil {
+		return nil, err
+	}
+	result := res.(serverFormModel).result
+	if result == nil {
+		return nil, ErrFormCancelled
+	}
+	return result, nil
+}
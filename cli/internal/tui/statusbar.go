@@ -1,20 +1,35 @@
 package tui
 
-import "fmt"
+import (
+	"fmt"
+	"time"
 
-func renderStatusBar(connected bool, sessionStatus string, serverName string, width int) string {
+	"github.com/arvid/remote-ai-ide/cli/internal/client"
+	"github.com/charmbracelet/lipgloss"
+)
+
+func renderStatusBar(connected bool, reconnecting *client.Reconnecting, rtt time.Duration, queued int, sessionStatus string, serverName string, width int) string {
 	var connDot string
-	if connected {
+	switch {
+	case connected && rtt > 0:
+		connDot = statusConnected.Render("● Connected · " + formatRTT(rtt))
+	case connected:
 		connDot = statusConnected.Render("● Connected")
-	} else {
+	case reconnecting != nil:
+		connDot = statusReconnecting.Render(fmt.Sprintf("● Reconnecting (%d/%d)...", reconnecting.Attempt, reconnecting.MaxAttempts))
+	default:
 		connDot = statusDisconnected.Render("● Disconnected")
 	}
 
 	center := fmt.Sprintf("Session: %s", sessionStatus)
+	if queued > 0 {
+		// Held back by the rate limit or until the connection is back
+		center += fmt.Sprintf(" · %d queued", queued)
+	}
 	right := serverName
 
 	// Pad to fill width
-	usedLen := len("● Connected") + len(center) + len(right) + 4
+	usedLen := lipgloss.Width(connDot) + len(center) + len(right) + 4
 	gap := width - usedLen
 	if gap < 2 {
 		gap = 2
@@ -25,3 +40,10 @@ func renderStatusBar(connected bool, sessionStatus string, serverName string, wi
 	bar := fmt.Sprintf("%s%*s%s%*s%s", connDot, leftPad, "", center, rightPad, "", right)
 	return statusBarStyle.Width(width).Render(bar)
 }
+
+func formatRTT(rtt time.Duration) string {
+	if rtt < time.Millisecond {
+		return "<1ms"
+	}
+	return fmt.Sprintf("%dms", rtt.Milliseconds())
+}
@@ -13,6 +13,21 @@ var (
 	streamStyle = lipgloss.NewStyle().
 		Foreground(lipgloss.Color("250"))
 
+	toolStyle = lipgloss.NewStyle().
+		Foreground(lipgloss.Color("14")).
+		Bold(true)
+
+	toolSummaryStyle = lipgloss.NewStyle().
+		Foreground(lipgloss.Color("245"))
+
+	toolInputStyle = lipgloss.NewStyle().
+		Foreground(lipgloss.Color("242"))
+
+	toolSelectedStyle = lipgloss.NewStyle().
+		Background(lipgloss.Color("238")).
+		Foreground(lipgloss.Color("14")).
+		Bold(true)
+
 	errorStyle = lipgloss.NewStyle().
 		Foreground(lipgloss.Color("9")).
 		Bold(true)
@@ -34,6 +49,9 @@ var (
 	statusConnected = lipgloss.NewStyle().
 		Foreground(lipgloss.Color("10"))
 
+	statusReconnecting = lipgloss.NewStyle().
+		Foreground(lipgloss.Color("11"))
+
 	statusDisconnected = lipgloss.NewStyle().
 		Foreground(lipgloss.Color("9"))
 
@@ -42,6 +60,32 @@ var (
 		Foreground(lipgloss.Color("252")).
 		Padding(0, 1)
 
+	searchMatchStyle = lipgloss.NewStyle().
+		Background(lipgloss.Color("58")).
+		Foreground(lipgloss.Color("230"))
+
+	searchCurrentStyle = lipgloss.NewStyle().
+		Background(lipgloss.Color("11")).
+		Foreground(lipgloss.Color("0")).
+		Bold(true)
+
+	searchHintStyle = lipgloss.NewStyle().
+		Foreground(lipgloss.Color("245"))
+
+	pendingStyle = lipgloss.NewStyle().
+		Foreground(lipgloss.Color("11")).
+		Italic(true)
+
+	tabStyle = lipgloss.NewStyle().
+		Foreground(lipgloss.Color("245")).
+		Padding(0, 1)
+
+	activeTabStyle = lipgloss.NewStyle().
+		Background(lipgloss.Color("238")).
+		Foreground(lipgloss.Color("255")).
+		Bold(true).
+		Padding(0, 1)
+
 	inputPrefixStyle = lipgloss.NewStyle().
 		Foreground(lipgloss.Color("12")).
 		Bold(true)
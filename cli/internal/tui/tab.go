@@ -0,0 +1,308 @@
+package tui
+
+import (
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"strings"
+
+	"github.com/arvid/remote-ai-ide/cli/internal/client"
+	"github.com/arvid/remote-ai-ide/cli/internal/policy"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// tab holds the state of one session. All tabs share the WebSocket; frames
+// are routed to their tab by session ID, so background tabs keep streaming.
+type tab struct {
+	sessionID string
+	project   string
+	policy    *policy.Policy
+
+	messages  []chatMessage
+	streamBuf string
+	shown     string // text of this turn moved out of streamBuf
+	lastPart  int    // index of the entry shown holds the end of
+	lastSeq   int    // highest server seq seen for this session
+	status    string // ready, busy, error
+
+	editing int  // outbox ID of the pending message in the composer
+	syncing bool // catching up after a reconnect; the outbox waits for it
+
+	permReq  *client.PermissionRequest
+	permRule string // ask rule that fired for permReq, if any
+	unread   bool   // output arrived while the tab was in the background
+	selected int    // index of the tool call picked in history mode, or -1
+	view     chatView
+
+	// Saved while another tab is active
+	draft   string
+	follow  bool // keep the viewport pinned to the newest output
+	yOffset int
+}
+
+func newTab(sessionID, project string, pol *policy.Policy) *tab {
+	return &tab{sessionID: sessionID, project: project, policy: pol, status: "ready", follow: true, selected: -1}
+}
+
+// newTabMsg carries a session created for a new tab.
+type newTabMsg struct {
+	tab *tab
+	err error
+}
+
+// openTab creates a session for project and builds its tab.
+func openTab(rest *client.RESTClient, loadPolicy func(string) (*policy.Policy, error), project string) tea.Cmd {
+	return func() tea.Msg {
+		pol, err := loadPolicy(project)
+		if err != nil {
+			return newTabMsg{err: err}
+		}
+		session, err := rest.CreateSession(project)
+		if err != nil {
+			return newTabMsg{err: fmt.Errorf("create session: %w", err)}
+		}
+		return newTabMsg{tab: newTab(session.ID, project, pol)}
+	}
+}
+
+// applyHistory merges REST history into the chat, skipping entries already
+// shown and confirming local user messages the server has recorded.
+func (t *tab) applyHistory(history []client.HistoryMessage) {
+	for _, h := range history {
+		if h.Seq > t.lastSeq {
+			t.lastSeq = h.Seq
+		}
+		if t.hasSeq(h.Seq) {
+			continue
+		}
+		if h.Role == "user" {
+			if i := t.unconfirmedUser(h.Content); i >= 0 {
+				t.messages[i].Seq = h.Seq
+				continue
+			}
+		}
+		if h.Role == "assistant" {
+			// The turn finished while we were away; its stream is complete
+			t.finishTurn(h.Content, h.Seq)
+			continue
+		}
+		t.messages = append(t.messages, chatMessage{Role: h.Role, Content: h.Content, Seq: h.Seq})
+	}
+}
+
+func (t *tab) hasSeq(seq int) bool {
+	for _, cm := range t.messages {
+		if cm.Seq == seq && cm.Seq != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *tab) unconfirmedUser(content string) int {
+	for i, cm := range t.messages {
+		if cm.Role == "user" && cm.Seq == 0 && (cm.Pending == 0 || cm.Sending) && cm.Content == content {
+			return i
+		}
+	}
+	return -1
+}
+
+// hasPending reports whether t shows a user message still in the outbox.
+func (t *tab) hasPending() bool {
+	for _, cm := range t.messages {
+		if cm.Pending != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// pendingIndex finds the chat entry of outbox message id.
+func (t *tab) pendingIndex(id int) int {
+	for i, cm := range t.messages {
+		if cm.Pending == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// flushStream ends the text streamed so far as an entry of its own, so a
+// tool call is shown below the text that preceded it.
+func (t *tab) flushStream() {
+	if t.streamBuf == "" {
+		return
+	}
+	t.messages = append(t.messages, chatMessage{Role: "assistant", Content: t.streamBuf})
+	t.lastPart = len(t.messages) - 1
+	t.shown += t.streamBuf
+	t.streamBuf = ""
+}
+
+// finishTurn adds the reply that ends a turn. Text already shown above the
+// turn's tool calls is not repeated; when nothing is left, the last part
+// shown takes the reply's seq.
+func (t *tab) finishTurn(content string, seq int) {
+	shown := t.shown
+	t.streamBuf = ""
+	t.shown = ""
+	if shown != "" {
+		switch {
+		case strings.HasPrefix(content, shown):
+			content = content[len(shown):]
+		case strings.HasSuffix(shown, content):
+			content = ""
+		}
+		if p := t.lastPart; strings.TrimSpace(content) == "" && p < len(t.messages) && t.messages[p].Role == "assistant" {
+			t.messages[p].Seq = seq
+			return
+		}
+	}
+	t.messages = append(t.messages, chatMessage{Role: "assistant", Content: content, Seq: seq})
+}
+
+// toolIndex returns the tool call delta calls away from the selected one,
+// wrapping around; with none selected, the first call counts forward and
+// the last one backward. It returns -1 if there is no tool call.
+func (t *tab) toolIndex(delta int) int {
+	n := len(t.messages)
+	i := t.selected
+	if i < 0 && delta < 0 {
+		i = n
+	}
+	for range n {
+		i = ((i+delta)%n + n) % n
+		if t.messages[i].Role == "tool" {
+			return i
+		}
+	}
+	return -1
+}
+
+func (t *tab) observeSeq(seq int) {
+	if seq > t.lastSeq {
+		t.lastSeq = seq
+	}
+}
+
+// handleFrame applies a server frame to the tab, answering permission
+// requests the policy decides. Tool paths are shown as local paths, but
+// rules are matched against what the server sent.
+func (t *tab) handleFrame(s *sender, paths PathMapper, log *slog.Logger, ev client.Event) {
+	switch ev := ev.(type) {
+	case *client.AssistantChunk:
+		t.observeSeq(ev.Seq)
+		t.streamBuf += ev.Content
+
+	case *client.AssistantMessageMsg:
+		t.observeSeq(ev.Seq)
+		if t.hasSeq(ev.Seq) {
+			// Already fetched over REST by a resync that won the race
+			t.streamBuf = ""
+			t.shown = ""
+			return
+		}
+		content := ev.Content
+		if content == "" {
+			content = t.shown + t.streamBuf
+		}
+		t.finishTurn(content, ev.Seq)
+
+	case *client.PermissionRequest:
+		req := ev
+		d := t.policy.Decide(req.ToolName, req.ToolInput)
+		log.Info("permission request", "session", t.sessionID, "tool", req.ToolName, "action", d.Action, "rule", d.Rule)
+		switch d.Action {
+		case policy.Allow:
+			s.send(client.NewPermissionResponse(t.sessionID, req.RequestID, true))
+			t.messages = append(t.messages, chatMessage{
+				Role:    "assistant",
+				Content: "✓ Allowed: " + req.ToolName + " (rule " + d.Rule + ")",
+			})
+		case policy.Deny:
+			s.send(client.NewPermissionResponse(t.sessionID, req.RequestID, false))
+			t.messages = append(t.messages, chatMessage{
+				Role:    "error",
+				Content: "✗ Denied: " + req.ToolName + " (rule " + d.Rule + ")",
+			})
+		default:
+			shown := *req
+			shown.ToolInput = localizeInput(paths, req.ToolInput)
+			t.permReq = &shown
+			t.permRule = d.Rule
+		}
+
+	case *client.ToolEvent:
+		t.observeSeq(ev.Seq)
+		if t.hasSeq(ev.Seq) {
+			return
+		}
+		t.flushStream()
+		t.messages = append(t.messages, chatMessage{Role: "tool", ToolName: ev.ToolName, ToolInput: localizeInput(paths, ev.ToolInput), Seq: ev.Seq})
+
+	case *client.SessionState:
+		t.status = ev.Status
+
+	case *client.ResultMessage:
+		t.observeSeq(ev.Seq)
+		if !ev.Success && ev.SessionID == t.sessionID {
+			// The turn failed; the text shown of it stays as it is
+			t.shown = ""
+		}
+		if !ev.Success && ev.Error != "" {
+			t.messages = append(t.messages, chatMessage{Role: "error", Content: ev.Error})
+		}
+	}
+}
+
+// label names the tab after its project and session.
+func (t *tab) label() string {
+	name := filepath.Base(t.project)
+	if name == "." || name == string(filepath.Separator) {
+		name = t.project
+	}
+	id := t.sessionID
+	if len(id) > 8 {
+		id = id[:8]
+	}
+	return name + " " + id
+}
+
+// renderTabBar lists the tabs, each with a dot for its session state.
+func renderTabBar(tabs []*tab, active, width int) string {
+	parts := make([]string, len(tabs))
+	for i, t := range tabs {
+		dot := statusDot(t.status).Render("●")
+		label := fmt.Sprintf("%d %s", i+1, t.label())
+		switch {
+		case t.permReq != nil:
+			label += " ?"
+		case t.unread:
+			label += " *"
+		}
+		style := tabStyle
+		if i == active {
+			style = activeTabStyle
+		}
+		parts[i] = dot + style.Render(label)
+	}
+	bar := strings.Join(parts, " ")
+	if width > 0 {
+		bar = ansi.Truncate(bar, width, "…")
+	}
+	return bar
+}
+
+func statusDot(status string) lipgloss.Style {
+	switch status {
+	case "busy":
+		return statusReconnecting
+	case "error":
+		return statusDisconnected
+	default:
+		return statusConnected
+	}
+}
@@ -0,0 +1,179 @@
+package tui
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/arvid/remote-ai-ide/cli/internal/client"
+)
+
+// chatLines describes t's chat as role:content@seq, one entry each.
+func chatLines(t *tab) []string {
+	var out []string
+	for _, m := range t.messages {
+		out = append(out, fmt.Sprintf("%s:%s@%d", m.Role, m.Content, m.Seq))
+	}
+	return out
+}
+
+func TestFinishTurn(t *testing.T) {
+	tests := []struct {
+		name     string
+		messages []chatMessage
+		shown    string
+		stream   string
+		content  string
+		want     []string
+	}{
+		{
+			name:    "nothing shown",
+			stream:  "Hel",
+			content: "Hello",
+			want:    []string{"assistant:Hello@7"},
+		},
+		{
+			name:     "shown is a prefix",
+			messages: []chatMessage{{Role: "assistant", Content: "Let me look."}, {Role: "tool"}},
+			shown:    "Let me look.",
+			stream:   " Fou",
+			content:  "Let me look. Found it.",
+			want:     []string{"assistant:Let me look.@0", "tool:@0", "assistant: Found it.@7"},
+		},
+		{
+			name:     "shown ends with the reply",
+			messages: []chatMessage{{Role: "assistant", Content: "Checking."}, {Role: "tool"}, {Role: "assistant", Content: "Done."}},
+			shown:    "Checking.Done.",
+			content:  "Done.",
+			want:     []string{"assistant:Checking.@0", "tool:@0", "assistant:Done.@7"},
+		},
+		{
+			name:     "nothing left",
+			messages: []chatMessage{{Role: "tool"}, {Role: "assistant", Content: "All done."}},
+			shown:    "All done.",
+			content:  "All done.",
+			want:     []string{"tool:@0", "assistant:All done.@7"},
+		},
+		{
+			name:     "only whitespace left",
+			messages: []chatMessage{{Role: "tool"}, {Role: "assistant", Content: "All done."}},
+			shown:    "All done.",
+			content:  "All done.\n",
+			want:     []string{"tool:@0", "assistant:All done.@7"},
+		},
+		{
+			name:     "reply differs from what was shown",
+			messages: []chatMessage{{Role: "assistant", Content: "Draft"}, {Role: "tool"}},
+			shown:    "Draft",
+			content:  "Final answer",
+			want:     []string{"assistant:Draft@0", "tool:@0", "assistant:Final answer@7"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tb := newTab("s", "/p", nil)
+			tb.messages = tt.messages
+			tb.shown, tb.streamBuf = tt.shown, tt.stream
+			for i, m := range tt.messages {
+				if m.Role == "assistant" {
+					tb.lastPart = i
+				}
+			}
+			tb.finishTurn(tt.content, 7)
+			if got := chatLines(tb); !slices.Equal(got, tt.want) {
+				t.Errorf("chat = %q, want %q", got, tt.want)
+			}
+			if tb.shown != "" || tb.streamBuf != "" {
+				t.Errorf("shown = %q, streamBuf = %q after the turn, want both empty", tb.shown, tb.streamBuf)
+			}
+		})
+	}
+}
+
+func TestApplyHistory(t *testing.T) {
+	history := []client.HistoryMessage{
+		{Role: "user", Content: "hi", Seq: 1},
+		{Role: "assistant", Content: "hello", Seq: 2},
+	}
+	final := &client.AssistantMessageMsg{Type: "assistant_message", SessionID: "s", Content: "hello", Seq: 2}
+	tests := []struct {
+		name   string
+		before func(tb *tab)
+		after  func(tb *tab)
+		want   []string
+	}{
+		{
+			name: "confirms a local user message",
+			before: func(tb *tab) {
+				tb.messages = []chatMessage{{Role: "user", Content: "hi"}}
+			},
+			want: []string{"user:hi@1", "assistant:hello@2"},
+		},
+		{
+			name: "keeps a pending user message apart",
+			before: func(tb *tab) {
+				tb.messages = []chatMessage{{Role: "user", Content: "hi", Pending: 3}}
+			},
+			want: []string{"user:hi@0", "user:hi@1", "assistant:hello@2"},
+		},
+		{
+			name: "skips entries already shown",
+			before: func(tb *tab) {
+				tb.messages = []chatMessage{{Role: "user", Content: "hi", Seq: 1}, {Role: "assistant", Content: "hello", Seq: 2}}
+			},
+			want: []string{"user:hi@1", "assistant:hello@2"},
+		},
+		{
+			name: "final reply before the resync",
+			before: func(tb *tab) {
+				tb.messages = []chatMessage{{Role: "user", Content: "hi", Seq: 1}}
+				tb.handleFrame(nil, nil, nil, &client.AssistantChunk{Type: "assistant_chunk", SessionID: "s", Content: "hel", Seq: 2})
+				tb.handleFrame(nil, nil, nil, final)
+			},
+			want: []string{"user:hi@1", "assistant:hello@2"},
+		},
+		{
+			name: "final reply after the resync",
+			before: func(tb *tab) {
+				tb.messages = []chatMessage{{Role: "user", Content: "hi", Seq: 1}}
+				tb.handleFrame(nil, nil, nil, &client.AssistantChunk{Type: "assistant_chunk", SessionID: "s", Content: "hel", Seq: 2})
+			},
+			after: func(tb *tab) {
+				tb.handleFrame(nil, nil, nil, final)
+			},
+			want: []string{"user:hi@1", "assistant:hello@2"},
+		},
+		{
+			name: "final reply after the resync, with text shown before a tool call",
+			before: func(tb *tab) {
+				tb.messages = []chatMessage{{Role: "user", Content: "hi", Seq: 1}}
+				tb.handleFrame(nil, nil, nil, &client.AssistantChunk{Type: "assistant_chunk", SessionID: "s", Content: "hel", Seq: 2})
+				tb.flushStream()
+				tb.messages = append(tb.messages, chatMessage{Role: "tool"})
+			},
+			after: func(tb *tab) {
+				tb.handleFrame(nil, nil, nil, final)
+			},
+			want: []string{"user:hi@1", "assistant:hel@0", "tool:@0", "assistant:lo@2"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tb := newTab("s", "/p", nil)
+			tt.before(tb)
+			tb.applyHistory(history)
+			if tt.after != nil {
+				tt.after(tb)
+			}
+			if got := chatLines(tb); !slices.Equal(got, tt.want) {
+				t.Errorf("chat = %q, want %q", got, tt.want)
+			}
+			if tb.lastSeq != 2 {
+				t.Errorf("lastSeq = %d, want 2", tb.lastSeq)
+			}
+			if tb.streamBuf != "" || tb.shown != "" {
+				t.Errorf("shown = %q, streamBuf = %q after the turn, want both empty", tb.shown, tb.streamBuf)
+			}
+		})
+	}
+}